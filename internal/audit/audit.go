@@ -0,0 +1,21 @@
+package audit
+
+import (
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"log"
+	"time"
+)
+
+// Record stores an audit event. Failures are logged rather than returned so
+// auditing never breaks the request that triggered it.
+func Record(event models.AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	log.Printf("audit: action=%s actor=%d target=%s:%d ip=%s %s",
+		event.Action, event.ActorID, event.TargetType, event.TargetID, event.IP, event.Details)
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Println("audit error: failed to save event", err)
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoSigningKey = errors.New("no JWT signing key loaded")
+
+// signingKey is one entry of the key ring. Private is nil for keys that are
+// only kept to verify tokens signed before a rotation.
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+type keyRing struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var keys = &keyRing{keys: map[string]*signingKey{}}
+
+// LoadKeys reads every *.pem file in JWT_KEYS_DIR into the key ring, using the
+// file name as the key id. Files may hold RSA or Ed25519 keys, private (PKCS#1
+// or PKCS#8) or public (PKIX). Tokens are signed with JWT_ACTIVE_KID, which
+// may be omitted when the directory holds exactly one private key.
+//
+// To rotate, add the new key, restart with it active, and delete the old
+// file once every token it signed has expired.
+//
+// Without JWT_KEYS_DIR an ephemeral Ed25519 key is generated, so tokens do
+// not survive a restart.
+func LoadKeys() error {
+	ring := &keyRing{keys: map[string]*signingKey{}}
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		// Tokens signed with a throwaway key die on every restart and differ
+		// between replicas, which is only acceptable in development.
+		if os.Getenv("APP_ENV") != "dev" {
+			return errors.New("JWT_KEYS_DIR must be set unless APP_ENV=dev")
+		}
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		key := &signingKey{ID: "ephemeral", Method: jwt.SigningMethodEdDSA, Private: private, Public: public}
+		ring.keys[key.ID] = key
+		ring.active = key
+		keys = ring
+		log.Println("auth: JWT_KEYS_DIR not set, using an ephemeral signing key")
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := readKeyFile(id, file)
+		if err != nil {
+			return fmt.Errorf("load key %s: %w", id, err)
+		}
+		ring.keys[id] = key
+	}
+
+	activeID := os.Getenv("JWT_ACTIVE_KID")
+	if activeID == "" {
+		for _, key := range ring.keys {
+			if key.Private == nil {
+				continue
+			}
+			if activeID != "" {
+				return errors.New("JWT_ACTIVE_KID must be set when several private keys are loaded")
+			}
+			activeID = key.ID
+		}
+	}
+	active, ok := ring.keys[activeID]
+	if !ok || active.Private == nil {
+		return fmt.Errorf("active key %q has no private key in %s", activeID, dir)
+	}
+	ring.active = active
+	keys = ring
+	log.Printf("auth: loaded %d JWT keys, signing with %s", len(ring.keys), active.ID)
+	return nil
+}
+
+func readKeyFile(id string, file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+func sign(claims jwt.MapClaims) (string, error) {
+	active := keys.active
+	if active == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(active.Method, claims)
+	token.Header["kid"] = active.ID
+	return token.SignedString(active.Private)
+}
+
+// verificationKey picks the key named by the token's kid and refuses tokens
+// whose alg doesn't match that key.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := keys.keys[id]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.Public, nil
+}
+
+// JWKS returns the public half of every loaded key as a JSON Web Key Set.
+func JWKS() map[string]interface{} {
+	ids := make([]string, 0, len(keys.keys))
+	for id := range keys.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := []map[string]string{}
+	for _, id := range ids {
+		key := keys.keys[id]
+		jwk := map[string]string{
+			"kid": key.ID,
+			"use": "sig",
+			"alg": key.Method.Alg(),
+		}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set = append(set, jwk)
+	}
+	return map[string]interface{}{"keys": set}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+
+	"conductor_backend/internal/models"
+)
+
+// useTestKeys signs tokens with a throwaway key for the rest of the test.
+func useTestKeys(t *testing.T) {
+	t.Helper()
+	t.Setenv("JWT_KEYS_DIR", "")
+	t.Setenv("APP_ENV", "dev")
+	previous := keys
+	if err := LoadKeys(); err != nil {
+		t.Fatalf("load keys: %v", err)
+	}
+	t.Cleanup(func() {
+		keys = previous
+	})
+}
+
+func TestLoadKeysRequiresKeysOutsideDev(t *testing.T) {
+	previous := keys
+	t.Cleanup(func() {
+		keys = previous
+	})
+	tests := []struct {
+		env     string
+		wantErr bool
+	}{
+		{env: "dev"},
+		{env: "", wantErr: true},
+		{env: "production", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("JWT_KEYS_DIR", "")
+		t.Setenv("APP_ENV", tt.env)
+		if err := LoadKeys(); (err != nil) != tt.wantErr {
+			t.Errorf("APP_ENV=%q: err = %v, want error %v", tt.env, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSignedTokensVerify(t *testing.T) {
+	useTestKeys(t)
+	token, err := IssueAccessToken(models.User{ID: 42, Role: models.RoleStudent}, "session")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if _, err := ParseAccessToken(token); err != nil {
+		t.Errorf("parse with the signing key: %v", err)
+	}
+	useTestKeys(t)
+	if _, err := ParseAccessToken(token); err == nil {
+		t.Errorf("token signed with a rotated-out key still verifies")
+	}
+}
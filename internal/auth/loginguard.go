@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"conductor_backend/internal/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// loginLimit describes how failed logins are throttled for one scope.
+// After DelayAfter failures each further failure blocks the scope for a
+// doubling delay, and after LockAfter failures it is locked for Lockout.
+type loginLimit struct {
+	DelayAfter int64
+	LockAfter  int64
+	MaxDelay   time.Duration
+	Lockout    time.Duration
+	Window     time.Duration
+}
+
+var (
+	emailLoginLimit = loginLimit{DelayAfter: 3, LockAfter: 10, MaxDelay: time.Minute, Lockout: 15 * time.Minute, Window: time.Hour}
+	ipLoginLimit    = loginLimit{DelayAfter: 20, LockAfter: 100, MaxDelay: time.Minute, Lockout: time.Hour, Window: time.Hour}
+)
+
+func emailScope(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipScope(ip string) string {
+	return "ip:" + ip
+}
+
+func loginFailKey(scope string) string {
+	return "login_fail:" + scope
+}
+
+func loginBlockKey(scope string) string {
+	return "login_block:" + scope
+}
+
+// LoginRetryAfter reports how long the email or IP must wait before another
+// login attempt. Zero means the attempt may proceed.
+func LoginRetryAfter(email string, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, scope := range []string{emailScope(email), ipScope(ip)} {
+		ttl, err := database.RDB.PTTL(database.Ctx, loginBlockKey(scope)).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+		if ttl > wait {
+			wait = ttl
+		}
+	}
+	return wait, nil
+}
+
+// LoginLockout names a scope that has just been locked out.
+type LoginLockout struct {
+	Scope    string
+	Failures int64
+	Duration time.Duration
+}
+
+// RecordLoginFailure counts a failed attempt against the email and the IP and
+// returns the lockouts it triggered.
+func RecordLoginFailure(email string, ip string) ([]LoginLockout, error) {
+	lockouts := []LoginLockout{}
+	limits := map[string]loginLimit{
+		emailScope(email): emailLoginLimit,
+		ipScope(ip):       ipLoginLimit,
+	}
+	for scope, limit := range limits {
+		failures, err := database.RDB.Incr(database.Ctx, loginFailKey(scope)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if failures == 1 {
+			database.RDB.Expire(database.Ctx, loginFailKey(scope), limit.Window)
+		}
+		var block time.Duration
+		switch {
+		case failures >= limit.LockAfter:
+			block = limit.Lockout
+			lockouts = append(lockouts, LoginLockout{Scope: scope, Failures: failures, Duration: block})
+			database.RDB.Del(database.Ctx, loginFailKey(scope))
+		case failures >= limit.DelayAfter:
+			block = time.Duration(math.Pow(2, float64(failures-limit.DelayAfter))) * time.Second
+			if block > limit.MaxDelay {
+				block = limit.MaxDelay
+			}
+		}
+		if block > 0 {
+			if err := database.RDB.Set(database.Ctx, loginBlockKey(scope), 1, block).Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return lockouts, nil
+}
+
+// ClearLoginFailures lifts delays and lockouts for an email, after a
+// successful login or password reset.
+func ClearLoginFailures(email string) error {
+	scope := emailScope(email)
+	return database.RDB.Del(database.Ctx, loginFailKey(scope), loginBlockKey(scope)).Err()
+}
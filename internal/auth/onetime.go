@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"conductor_backend/internal/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	PasswordResetTTL     = 30 * time.Minute
+	EmailVerificationTTL = 24 * time.Hour
+	LoginCodeTTL         = time.Minute
+	MFAChallengeTTL      = 5 * time.Minute
+)
+
+var ErrInvalidOneTimeToken = errors.New("invalid or expired token")
+
+// issueOneTimeToken stores a random token for userID under purpose. Issuing a
+// new token invalidates the previous one with the same purpose.
+func issueOneTimeToken(purpose string, userID uint, ttl time.Duration) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	hash := hashToken(token)
+	userKey := fmt.Sprintf("%s_user:%d", purpose, userID)
+	previous, err := database.RDB.Get(database.Ctx, userKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+	if previous != "" {
+		database.RDB.Del(database.Ctx, purpose+":"+previous)
+	}
+	if err := database.RDB.Set(database.Ctx, purpose+":"+hash, userID, ttl).Err(); err != nil {
+		return "", err
+	}
+	if err := database.RDB.Set(database.Ctx, userKey, hash, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// peekOneTimeToken returns the user a token was issued for without using it
+// up.
+func peekOneTimeToken(purpose string, token string) (uint, error) {
+	val, err := database.RDB.Get(database.Ctx, purpose+":"+hashToken(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrInvalidOneTimeToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	userID, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidOneTimeToken
+	}
+	return uint(userID), nil
+}
+
+// consumeOneTimeToken returns the user a token was issued for and deletes it,
+// so each token works at most once.
+func consumeOneTimeToken(purpose string, token string) (uint, error) {
+	val, err := database.RDB.GetDel(database.Ctx, purpose+":"+hashToken(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrInvalidOneTimeToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	userID, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidOneTimeToken
+	}
+	database.RDB.Del(database.Ctx, fmt.Sprintf("%s_user:%d", purpose, userID))
+	return uint(userID), nil
+}
+
+func CreatePasswordResetToken(userID uint) (string, error) {
+	return issueOneTimeToken("password_reset", userID, PasswordResetTTL)
+}
+
+func ConsumePasswordResetToken(token string) (uint, error) {
+	return consumeOneTimeToken("password_reset", token)
+}
+
+func CreateEmailVerificationToken(userID uint) (string, error) {
+	return issueOneTimeToken("email_verify", userID, EmailVerificationTTL)
+}
+
+func ConsumeEmailVerificationToken(token string) (uint, error) {
+	return consumeOneTimeToken("email_verify", token)
+}
+
+// CreateLoginCode issues a short-lived code the frontend trades for tokens
+// after an external login, so tokens never appear in a redirect URL.
+func CreateLoginCode(userID uint) (string, error) {
+	return issueOneTimeToken("login_code", userID, LoginCodeTTL)
+}
+
+func ConsumeLoginCode(code string) (uint, error) {
+	return consumeOneTimeToken("login_code", code)
+}
+
+// CreateMFAChallenge issues the token that stands in for a login between the
+// password and the second factor.
+func CreateMFAChallenge(userID uint) (string, error) {
+	return issueOneTimeToken("mfa_challenge", userID, MFAChallengeTTL)
+}
+
+// PeekMFAChallenge resolves a challenge without using it up, so a mistyped
+// code can be retried.
+func PeekMFAChallenge(token string) (uint, error) {
+	return peekOneTimeToken("mfa_challenge", token)
+}
+
+func ConsumeMFAChallenge(token string) (uint, error) {
+	return consumeOneTimeToken("mfa_challenge", token)
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const permissionsTTL = 5 * time.Minute
+
+const (
+	RestrictionApprovalPending  = "approval_pending"
+	RestrictionMFASetupRequired = "mfa_setup_required"
+)
+
+// Grants is what a user may do, globally and within one course. Restriction
+// explains why a professor's roles were withheld, if they were.
+type Grants struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Restriction string   `json:"restriction,omitempty"`
+}
+
+func (g Grants) Has(permission string) bool {
+	for _, p := range g.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func permissionsKey(userID uint) string {
+	return fmt.Sprintf("perms:%d", userID)
+}
+
+// ResolvePermissions returns the user's grants for courseID, or only the
+// global ones when courseID is 0. Results are cached in Redis per user.
+func ResolvePermissions(userID uint, courseID uint) (Grants, error) {
+	field := strconv.FormatUint(uint64(courseID), 10)
+	val, err := database.RDB.HGet(database.Ctx, permissionsKey(userID), field).Result()
+	if err == nil {
+		var grants Grants
+		if json.Unmarshal([]byte(val), &grants) == nil {
+			return grants, nil
+		}
+	}
+
+	grants, err := loadGrants(userID, courseID)
+	if err != nil {
+		return Grants{}, err
+	}
+	cacheGrants(userID, field, grants)
+	return grants, nil
+}
+
+// cacheGrants stores grants in the user's hash. The hash expires
+// permissionsTTL after it was created; adding a course doesn't extend it, or
+// grants cached earlier could stay stale for good.
+func cacheGrants(userID uint, field string, grants Grants) {
+	bytes, _ := json.Marshal(grants)
+	key := permissionsKey(userID)
+	_, err := database.RDB.TxPipelined(database.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(database.Ctx, key, field, bytes)
+		pipe.ExpireNX(database.Ctx, key, permissionsTTL)
+		return nil
+	})
+	if err != nil {
+		log.Println("auth: failed to cache permissions", err)
+	}
+}
+
+// InvalidatePermissions drops the cached grants of a user. Call it whenever
+// their role, approval, two-factor state or course roles change.
+func InvalidatePermissions(userID uint) {
+	database.RDB.Del(database.Ctx, permissionsKey(userID))
+}
+
+// InvalidateCoursePermissions drops the cached grants of the course's
+// professor and staff. Call it when the course is trashed, restored or
+// archived, or gets a new professor.
+func InvalidateCoursePermissions(courseID uint) {
+	userIDs := []uint{}
+	staffIDs := []uint{}
+	err := database.DB.Unscoped().Model(&models.Course{}).Where("id = ?", courseID).Pluck("professor_id", &userIDs).Error
+	if err == nil {
+		err = database.DB.Model(&models.CourseRoleAssignment{}).Where("course_id = ?", courseID).Pluck("user_id", &staffIDs).Error
+	}
+	if err != nil {
+		log.Println("auth: failed to get course staff to invalidate permissions", err)
+		return
+	}
+	userIDs = append(userIDs, staffIDs...)
+	if len(userIDs) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		keys = append(keys, permissionsKey(userID))
+	}
+	database.RDB.Del(database.Ctx, keys...)
+}
+
+func loadGrants(userID uint, courseID uint) (Grants, error) {
+	user := models.User{}
+	err := database.DB.Select("id", "role", "approval_status", "mfa_enabled_at").Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		return Grants{}, err
+	}
+	return grantsFor(user, courseID)
+}
+
+// grantsFor resolves the grants of a loaded user. A restricted professor gets
+// nothing at all, including the roles they hold in courses, until they are
+// approved and have set up two-factor authentication where it is required.
+func grantsFor(user models.User, courseID uint) (Grants, error) {
+	grants := Grants{Roles: []string{}, Permissions: []string{}}
+	switch user.Role {
+	case models.RoleStudent:
+		grants.Roles = append(grants.Roles, models.GlobalRoleStudent)
+	case models.RoleAdmin:
+		grants.Roles = append(grants.Roles, models.GlobalRoleAdmin)
+	case models.RoleProfessor:
+		if user.ApprovalStatus != models.ApprovalApproved {
+			grants.Restriction = RestrictionApprovalPending
+		} else if MFARequired(user.Role) && !user.MFAEnabled() {
+			grants.Restriction = RestrictionMFASetupRequired
+		} else {
+			grants.Roles = append(grants.Roles, models.GlobalRoleProfessor)
+		}
+	}
+	if grants.Restriction != "" {
+		return grants, nil
+	}
+
+	if courseID != 0 {
+		course := models.Course{}
+		// Deleted courses are included so their trash can be managed.
+		if err := database.DB.Unscoped().Select("id", "professor_id").First(&course, courseID).Error; err != nil {
+			return Grants{}, err
+		}
+		if course.ProfessorID == user.ID {
+			grants.Roles = append(grants.Roles, models.CourseRoleInstructor)
+		}
+		courseRoles := []string{}
+		err := database.DB.Model(&models.CourseRoleAssignment{}).
+			Joins("JOIN access_roles ON access_roles.id = course_role_assignments.role_id").
+			Where("course_role_assignments.course_id = ? AND course_role_assignments.user_id = ?", courseID, user.ID).
+			Where("access_roles.scope = ?", models.RoleScopeCourse).
+			Pluck("access_roles.name", &courseRoles).Error
+		if err != nil {
+			return Grants{}, err
+		}
+		grants.Roles = append(grants.Roles, courseRoles...)
+	}
+
+	if len(grants.Roles) == 0 {
+		return grants, nil
+	}
+	err := database.DB.Model(&models.RolePermission{}).
+		Distinct("role_permissions.permission").
+		Joins("JOIN access_roles ON access_roles.id = role_permissions.role_id").
+		Where("access_roles.name IN ?", grants.Roles).
+		Order("role_permissions.permission").
+		Pluck("role_permissions.permission", &grants.Permissions).Error
+	if err != nil {
+		return Grants{}, err
+	}
+	return grants, nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"conductor_backend/internal/models"
+)
+
+func TestGrantsForRestrictedProfessors(t *testing.T) {
+	now := time.Now()
+	// Restricted professors get no roles, so grantsFor never reaches the
+	// database, which these tests don't have. Their course roles, such as
+	// co_instructor, are withheld as well.
+	tests := []struct {
+		name            string
+		user            models.User
+		mfaRequired     bool
+		wantRestriction string
+	}{
+		{
+			name:            "pending approval",
+			user:            models.User{ID: 1, Role: models.RoleProfessor, ApprovalStatus: models.ApprovalPending},
+			wantRestriction: RestrictionApprovalPending,
+		},
+		{
+			name:            "rejected",
+			user:            models.User{ID: 1, Role: models.RoleProfessor, ApprovalStatus: models.ApprovalRejected, MFAEnabledAt: &now},
+			wantRestriction: RestrictionApprovalPending,
+		},
+		{
+			name:            "two-factor not set up",
+			user:            models.User{ID: 1, Role: models.RoleProfessor, ApprovalStatus: models.ApprovalApproved},
+			mfaRequired:     true,
+			wantRestriction: RestrictionMFASetupRequired,
+		},
+	}
+	for _, tt := range tests {
+		for _, courseID := range []uint{0, 5} {
+			t.Run(tt.name, func(t *testing.T) {
+				if tt.mfaRequired {
+					t.Setenv("MFA_REQUIRED_FOR_PROFESSORS", "true")
+				}
+				grants, err := grantsFor(tt.user, courseID)
+				if err != nil {
+					t.Fatalf("grantsFor: %v", err)
+				}
+				if grants.Restriction != tt.wantRestriction {
+					t.Errorf("restriction = %q, want %q", grants.Restriction, tt.wantRestriction)
+				}
+				if len(grants.Roles) != 0 || len(grants.Permissions) != 0 {
+					t.Errorf("course %d: got roles %v and permissions %v, want none", courseID, grants.Roles, grants.Permissions)
+				}
+			})
+		}
+	}
+}
+
+func TestCacheGrantsKeepsExpiry(t *testing.T) {
+	srv := newTestRedis(t)
+	key := permissionsKey(7)
+	cacheGrants(7, "0", Grants{Roles: []string{models.GlobalRoleStudent}})
+	if ttl := srv.TTL(key); ttl != permissionsTTL {
+		t.Fatalf("new hash expires in %v, want %v", ttl, permissionsTTL)
+	}
+	srv.FastForward(4 * time.Minute)
+	cacheGrants(7, "5", Grants{Roles: []string{models.CourseRoleTA}})
+	if ttl := srv.TTL(key); ttl != permissionsTTL-4*time.Minute {
+		t.Errorf("hash expires in %v after adding a course, want %v", ttl, permissionsTTL-4*time.Minute)
+	}
+	srv.FastForward(time.Minute)
+	if srv.Exists(key) {
+		t.Errorf("cached grants outlived %v", permissionsTTL)
+	}
+}
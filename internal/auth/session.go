@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"conductor_backend/internal/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// Session is one login. Every refresh token issued for it belongs to the
+// same rotation family, so revoking the session revokes the whole family.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     uint      `json:"userId"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// ClientInfo describes the client a session was created from.
+type ClientInfo struct {
+	Device    string
+	IP        string
+	UserAgent string
+}
+
+// lastSeenInterval throttles how often a request refreshes LastSeenAt.
+const lastSeenInterval = time.Minute
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func userSessionsKey(userID uint) string {
+	return fmt.Sprintf("user_sessions:%d", userID)
+}
+
+func refreshKey(tokenHash string) string {
+	return "refresh:" + tokenHash
+}
+
+func refreshUsedKey(tokenHash string) string {
+	return "refresh_used:" + tokenHash
+}
+
+// CreateSession starts a new session for userID and returns it together with
+// the first refresh token of its family.
+func CreateSession(userID uint, client ClientInfo) (Session, string, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return Session{}, "", err
+	}
+	now := time.Now()
+	session := Session{
+		ID:         id,
+		UserID:     userID,
+		Device:     client.Device,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	if err := saveSession(session); err != nil {
+		return Session{}, "", err
+	}
+	if err := trackSession(session); err != nil {
+		return Session{}, "", err
+	}
+	refreshToken, err := issueRefreshToken(id)
+	if err != nil {
+		return Session{}, "", err
+	}
+	return session, refreshToken, nil
+}
+
+// trackSession adds a session to its user's set. The set lives as long as
+// the user's newest session, so its expiry restarts with every login and
+// rotation.
+func trackSession(session Session) error {
+	key := userSessionsKey(session.UserID)
+	if err := database.RDB.SAdd(database.Ctx, key, session.ID).Err(); err != nil {
+		return err
+	}
+	return database.RDB.Expire(database.Ctx, key, RefreshTokenTTL).Err()
+}
+
+func saveSession(session Session) error {
+	bytes, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return database.RDB.Set(database.Ctx, sessionKey(session.ID), bytes, RefreshTokenTTL).Err()
+}
+
+// updateSession rewrites a live session. With extend its expiry restarts at
+// RefreshTokenTTL, otherwise it is kept. The write only happens while the key
+// exists, so a session revoked in the meantime is never brought back; that
+// case fails with redis.Nil.
+func updateSession(session Session, extend bool) error {
+	bytes, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	args := redis.SetArgs{Mode: "XX", KeepTTL: true}
+	if extend {
+		args = redis.SetArgs{Mode: "XX", TTL: RefreshTokenTTL}
+	}
+	return database.RDB.SetArgs(database.Ctx, sessionKey(session.ID), bytes, args).Err()
+}
+
+func GetSession(sessionID string) (Session, error) {
+	val, err := database.RDB.Get(database.Ctx, sessionKey(sessionID)).Result()
+	if err != nil {
+		return Session{}, err
+	}
+	var session Session
+	if err := json.Unmarshal([]byte(val), &session); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// TouchSession records activity on a session and returns it. It fails with
+// redis.Nil when the session has been revoked or has expired.
+func TouchSession(sessionID string, ip string) (Session, error) {
+	session, err := GetSession(sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	if time.Since(session.LastSeenAt) < lastSeenInterval && session.IP == ip {
+		return session, nil
+	}
+	session.LastSeenAt = time.Now()
+	session.IP = ip
+	if err := updateSession(session, false); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// ListUserSessions returns the live sessions of a user, dropping ids of
+// sessions that have since expired.
+func ListUserSessions(userID uint) ([]Session, error) {
+	ids, err := database.RDB.SMembers(database.Ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := []Session{}
+	for _, id := range ids {
+		session, err := GetSession(id)
+		if errors.Is(err, redis.Nil) {
+			database.RDB.SRem(database.Ctx, userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func issueRefreshToken(sessionID string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := database.RDB.Set(database.Ctx, refreshKey(hashToken(token)), sessionID, RefreshTokenTTL).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// lookupRefreshToken returns the live session a refresh token belongs to.
+func lookupRefreshToken(refreshToken string) (Session, error) {
+	sessionID, err := database.RDB.Get(database.Ctx, refreshKey(hashToken(refreshToken))).Result()
+	if errors.Is(err, redis.Nil) {
+		return Session{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	session, err := GetSession(sessionID)
+	if errors.Is(err, redis.Nil) {
+		return Session{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same
+// family. Presenting a token that was already rotated revokes the session.
+func RotateRefreshToken(refreshToken string) (Session, string, error) {
+	session, err := lookupRefreshToken(refreshToken)
+	if err != nil {
+		return Session{}, "", err
+	}
+	first, err := database.RDB.SetNX(database.Ctx, refreshUsedKey(hashToken(refreshToken)), 1, RefreshTokenTTL).Result()
+	if err != nil {
+		return Session{}, "", err
+	}
+	if !first {
+		if err := RevokeSession(session.ID); err != nil {
+			return Session{}, "", err
+		}
+		return Session{}, "", ErrRefreshTokenReused
+	}
+	session.LastSeenAt = time.Now()
+	err = updateSession(session, true)
+	if errors.Is(err, redis.Nil) {
+		return Session{}, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Session{}, "", err
+	}
+	if err := trackSession(session); err != nil {
+		return Session{}, "", err
+	}
+	newToken, err := issueRefreshToken(session.ID)
+	if err != nil {
+		return Session{}, "", err
+	}
+	return session, newToken, nil
+}
+
+// RevokeRefreshToken ends the session the refresh token belongs to.
+func RevokeRefreshToken(refreshToken string) error {
+	session, err := lookupRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	return RevokeSession(session.ID)
+}
+
+func RevokeSession(sessionID string) error {
+	session, err := GetSession(sessionID)
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := database.RDB.Del(database.Ctx, sessionKey(sessionID)).Err(); err != nil {
+		return err
+	}
+	return database.RDB.SRem(database.Ctx, userSessionsKey(session.UserID), sessionID).Err()
+}
+
+// RevokeUserSessions ends every session of a user, e.g. after a ban or a
+// password change.
+func RevokeUserSessions(userID uint) error {
+	ids, err := database.RDB.SMembers(database.Ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id))
+	}
+	keys = append(keys, userSessionsKey(userID))
+	return database.RDB.Del(database.Ctx, keys...).Err()
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"conductor_backend/internal/database"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis points database.RDB at an in-memory Redis for the rest of
+// the test.
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	previous := database.RDB
+	database.RDB = client
+	t.Cleanup(func() {
+		client.Close()
+		database.RDB = previous
+	})
+	return srv
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// present returns the refresh token to rotate, given the session and
+		// its first token.
+		present     func(t *testing.T, session Session, token string) string
+		wantErr     error
+		wantSession bool
+	}{
+		{
+			name: "first use",
+			present: func(t *testing.T, session Session, token string) string {
+				return token
+			},
+			wantSession: true,
+		},
+		{
+			name: "reused token revokes the session",
+			present: func(t *testing.T, session Session, token string) string {
+				if _, _, err := RotateRefreshToken(token); err != nil {
+					t.Fatalf("first rotation: %v", err)
+				}
+				return token
+			},
+			wantErr: ErrRefreshTokenReused,
+		},
+		{
+			name: "rotated token keeps working",
+			present: func(t *testing.T, session Session, token string) string {
+				_, next, err := RotateRefreshToken(token)
+				if err != nil {
+					t.Fatalf("first rotation: %v", err)
+				}
+				return next
+			},
+			wantSession: true,
+		},
+		{
+			name: "unknown token",
+			present: func(t *testing.T, session Session, token string) string {
+				return "not-a-token"
+			},
+			wantErr:     ErrInvalidRefreshToken,
+			wantSession: true,
+		},
+		{
+			name: "revoked session",
+			present: func(t *testing.T, session Session, token string) string {
+				if err := RevokeSession(session.ID); err != nil {
+					t.Fatalf("revoke: %v", err)
+				}
+				return token
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestRedis(t)
+			session, token, err := CreateSession(7, ClientInfo{Device: "laptop", IP: "10.0.0.1"})
+			if err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+			presented := tt.present(t, session, token)
+
+			got, next, err := RotateRefreshToken(presented)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got.ID != session.ID {
+					t.Errorf("session = %q, want %q", got.ID, session.ID)
+				}
+				if next == "" || next == presented {
+					t.Errorf("rotation returned token %q", next)
+				}
+			}
+			if alive := srv.Exists(sessionKey(session.ID)); alive != tt.wantSession {
+				t.Errorf("session alive = %v, want %v", alive, tt.wantSession)
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenExtendsExpiry(t *testing.T) {
+	srv := newTestRedis(t)
+	session, token, err := CreateSession(7, ClientInfo{})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	tracked := []string{sessionKey(session.ID), userSessionsKey(session.UserID)}
+	for _, key := range tracked {
+		srv.SetTTL(key, time.Hour)
+	}
+	if _, _, err := RotateRefreshToken(token); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	for _, key := range tracked {
+		if ttl := srv.TTL(key); ttl != RefreshTokenTTL {
+			t.Errorf("%s expires in %v, want %v", key, ttl, RefreshTokenTTL)
+		}
+	}
+}
+
+func TestTouchSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		revoke  bool
+		wantErr error
+	}{
+		{name: "live session keeps its expiry"},
+		{name: "revoked session stays revoked", revoke: true, wantErr: redis.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestRedis(t)
+			session, _, err := CreateSession(7, ClientInfo{IP: "10.0.0.1"})
+			if err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+			key := sessionKey(session.ID)
+			srv.SetTTL(key, time.Hour)
+			if tt.revoke {
+				if err := RevokeSession(session.ID); err != nil {
+					t.Fatalf("revoke: %v", err)
+				}
+			}
+
+			got, err := TouchSession(session.ID, "10.0.0.2")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.revoke {
+				if srv.Exists(key) {
+					t.Errorf("revoked session was recreated")
+				}
+				return
+			}
+			if got.IP != "10.0.0.2" {
+				t.Errorf("ip = %q, want %q", got.IP, "10.0.0.2")
+			}
+			if ttl := srv.TTL(key); ttl != time.Hour {
+				t.Errorf("session expires in %v, want %v", ttl, time.Hour)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"conductor_backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+func IssueAccessToken(user models.User, sessionID string) (string, error) {
+	now := time.Now()
+	return sign(jwt.MapClaims{
+		"sub":  user.ID,
+		"role": user.Role,
+		"sid":  sessionID,
+		"iat":  now.Unix(),
+		"exp":  now.Add(AccessTokenTTL).Unix(),
+	})
+}
+
+func ParseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["sub"].(float64); !ok {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["role"].(float64); !ok {
+		return nil, ErrInvalidToken
+	}
+	if _, ok := claims["sid"].(string); !ok {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// IssueInvitationToken signs the token of an invitation's accept link. It
+// expires together with the invitation.
+func IssueInvitationToken(invitationID uint, expiresAt time.Time) (string, error) {
+	return sign(jwt.MapClaims{
+		"typ": "invitation",
+		"inv": invitationID,
+		"iat": time.Now().Unix(),
+		"exp": expiresAt.Unix(),
+	})
+}
+
+// ParseInvitationToken returns the invitation ID of a valid accept link
+// token.
+func ParseInvitationToken(tokenString string) (uint, error) {
+	token, err := jwt.Parse(tokenString, verificationKey, jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return 0, ErrInvalidToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != "invitation" {
+		return 0, ErrInvalidToken
+	}
+	id, ok := claims["inv"].(float64)
+	if !ok || id <= 0 {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// randomToken returns n random bytes encoded as hex.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
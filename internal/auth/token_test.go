@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"conductor_backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseInvitationToken(t *testing.T) {
+	useTestKeys(t)
+	valid, err := IssueInvitationToken(42, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	expired, _ := IssueInvitationToken(42, time.Now().Add(-time.Minute))
+	access, _ := IssueAccessToken(models.User{ID: 42}, "session")
+	noExpiry, _ := sign(jwt.MapClaims{"typ": "invitation", "inv": 42})
+	noInvitation, _ := sign(jwt.MapClaims{"typ": "invitation", "exp": time.Now().Add(time.Hour).Unix()})
+	zeroInvitation, _ := sign(jwt.MapClaims{"typ": "invitation", "inv": 0, "exp": time.Now().Add(time.Hour).Unix()})
+	tampered := valid[:len(valid)-4] + "AAAA"
+	if tampered == valid {
+		tampered = valid[:len(valid)-4] + "BBBB"
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantID  uint
+		wantErr error
+	}{
+		{name: "valid", token: valid, wantID: 42},
+		{name: "expired", token: expired, wantErr: ErrInvalidToken},
+		{name: "access token", token: access, wantErr: ErrInvalidToken},
+		{name: "no expiry", token: noExpiry, wantErr: ErrInvalidToken},
+		{name: "no invitation", token: noInvitation, wantErr: ErrInvalidToken},
+		{name: "zero invitation", token: zeroInvitation, wantErr: ErrInvalidToken},
+		{name: "tampered signature", token: tampered, wantErr: ErrInvalidToken},
+		{name: "garbage", token: "not.a.token", wantErr: ErrInvalidToken},
+		{name: "empty", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := ParseInvitationToken(tt.token)
+			if !errors.Is(err, tt.wantErr) || id != tt.wantID {
+				t.Errorf("ParseInvitationToken = (%d, %v), want (%d, %v)", id, err, tt.wantID, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("signed with another key", func(t *testing.T) {
+		useTestKeys(t)
+		if _, err := ParseInvitationToken(valid); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("err = %v, want %v", err, ErrInvalidToken)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"conductor_backend/internal/models"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is how many periods before or after now are still accepted,
+	// to tolerate clock drift on the user's device.
+	totpSkew   = 1
+	totpIssuer = "Conductor"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI is the otpauth:// URI authenticator apps read from a
+// QR code.
+func TOTPProvisioningURI(secret string, account string) string {
+	label := url.PathEscape(totpIssuer + ":" + account)
+	query := url.Values{
+		"secret": {secret},
+		"issuer": {totpIssuer},
+		"digits": {fmt.Sprint(totpDigits)},
+		"period": {fmt.Sprint(totpPeriod)},
+	}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// VerifyTOTP checks code against secret and returns the time step it matched.
+// Callers store the step and pass it back as lastStep so a code can't be
+// replayed.
+func VerifyTOTP(secret string, code string, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	now := time.Now().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// NewRecoveryCodes returns n one-time codes formatted like "abcd-efgh-ijkl".
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		raw, err := randomToken(6)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode normalises a recovery code and hashes it for storage.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return hashToken(code)
+}
+
+// MFARequired reports whether users with role must have two-factor enabled.
+// MFA_REQUIRED_FOR_PROFESSORS=true makes it mandatory for professors.
+func MFARequired(role int8) bool {
+	return role == models.RoleProfessor && os.Getenv("MFA_REQUIRED_FOR_PROFESSORS") == "true"
+}
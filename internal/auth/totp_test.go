@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to six digits.
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("new secret: %v", err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Now().Unix() / totpPeriod
+	codeAt := func(step int64) string {
+		return totpCode(key, step)
+	}
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: secret, code: codeAt(now), wantStep: now, wantOK: true},
+		{name: "previous step", secret: secret, code: codeAt(now - 1), wantStep: now - 1, wantOK: true},
+		{name: "next step", secret: secret, code: codeAt(now + 1), wantStep: now + 1, wantOK: true},
+		{name: "too old", secret: secret, code: codeAt(now - 2)},
+		{name: "too new", secret: secret, code: codeAt(now + 2)},
+		{name: "lowercase secret", secret: strings.ToLower(secret), code: codeAt(now), wantStep: now, wantOK: true},
+		{name: "spaces in code", secret: secret, code: codeAt(now)[:3] + " " + codeAt(now)[3:], wantStep: now, wantOK: true},
+		{name: "replayed code", secret: secret, code: codeAt(now), lastStep: now},
+		{name: "code older than the last one used", secret: secret, code: codeAt(now - 1), lastStep: now},
+		{name: "next code after the last one used", secret: secret, code: codeAt(now + 1), lastStep: now, wantStep: now + 1, wantOK: true},
+		{name: "short code", secret: secret, code: codeAt(now)[:5]},
+		{name: "empty code", secret: secret},
+		{name: "invalid secret", secret: "not base32!", code: codeAt(now)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(tt.secret, tt.code, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("VerifyTOTP = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListPendingProfessors(c *gin.Context) {
+	users := []models.User{}
+	err := database.DB.
+		Where("role = ? AND approval_status = ?", models.RoleProfessor, models.ApprovalPending).
+		Order("created_at").
+		Find(&users).Error
+	if err != nil {
+		log.Println("list pending professors error: failed to get users")
+		c.JSON(500, gin.H{"message": "Failed to get users"})
+		return
+	}
+	result := make([]gin.H, 0, len(users))
+	for _, user := range users {
+		result = append(result, gin.H{
+			"id":        user.ID,
+			"name":      user.Name,
+			"email":     user.Email,
+			"verified":  user.IsVerified(),
+			"createdAt": user.CreatedAt,
+		})
+	}
+	log.Println("list pending professors success: users found")
+	c.JSON(200, gin.H{"users": result})
+}
+
+func ApproveProfessor(c *gin.Context) {
+	setProfessorApproval(c, models.ApprovalApproved)
+}
+
+func RejectProfessor(c *gin.Context) {
+	setProfessorApproval(c, models.ApprovalRejected)
+}
+
+func setProfessorApproval(c *gin.Context, status string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		log.Println("set professor approval error: invalid user ID")
+		c.JSON(400, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ? AND role = ?", id, models.RoleProfessor).First(&user).Error; err != nil {
+		log.Println("set professor approval error: professor not found")
+		c.JSON(404, gin.H{"message": "Professor not found"})
+		return
+	}
+	if err := database.DB.Model(&user).Update("approval_status", status).Error; err != nil {
+		log.Println("set professor approval error: failed to save user", err)
+		c.JSON(500, gin.H{"message": "Failed to save user"})
+		return
+	}
+	database.RDB.Del(database.Ctx, fmt.Sprintf("user:%d", user.ID))
+	auth.InvalidatePermissions(user.ID)
+	if status != models.ApprovalApproved {
+		if err := auth.RevokeUserSessions(user.ID); err != nil {
+			log.Println("set professor approval error: failed to revoke sessions", err)
+		}
+	}
+	err = mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Conductor professor account",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour professor account has been %s.\n", user.Name, status),
+	})
+	if err != nil {
+		log.Println("set professor approval error: failed to send email", err)
+	}
+	log.Println("set professor approval success: professor", status)
+	c.JSON(200, gin.H{
+		"id":             user.ID,
+		"approvalStatus": status,
+	})
+}
+
+func ListRoles(c *gin.Context) {
+	roles := []models.AccessRole{}
+	if err := database.DB.Preload("Permissions").Order("scope, name").Find(&roles).Error; err != nil {
+		log.Println("list roles error: failed to get roles")
+		c.JSON(500, gin.H{"message": "Failed to get roles"})
+		return
+	}
+	result := make([]gin.H, 0, len(roles))
+	for _, role := range roles {
+		permissions := make([]string, 0, len(role.Permissions))
+		for _, p := range role.Permissions {
+			permissions = append(permissions, p.Permission)
+		}
+		result = append(result, gin.H{
+			"name":        role.Name,
+			"scope":       role.Scope,
+			"permissions": permissions,
+		})
+	}
+	log.Println("list roles success: roles found")
+	c.JSON(200, gin.H{"roles": result})
+}
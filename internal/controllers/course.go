@@ -1,21 +1,33 @@
 package controllers
 
 import (
+	"conductor_backend/internal/audit"
 	"conductor_backend/internal/database"
 	"conductor_backend/internal/models"
+	"conductor_backend/internal/ratelimit"
 	"errors"
+	"fmt"
 	"log"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
 type createCourseRequest struct {
-	Name string `json:"name"`
-	Code string `json:"code"`
+	Name             string `json:"name"`
+	Code             string `json:"code"`
+	RequiresApproval bool   `json:"requiresApproval"`
+	Capacity         *int   `json:"capacity"`
+	TermID           *uint  `json:"termId"`
 }
 
 func CreateCourse(c *gin.Context) {
@@ -25,6 +37,19 @@ func CreateCourse(c *gin.Context) {
 		c.JSON(400, gin.H{"message": "Invalid request"})
 		return
 	}
+	if fieldErrors := validateCourseFields(&req.Name, &req.Code, req.Capacity); len(fieldErrors) > 0 {
+		log.Println("create course error: invalid fields")
+		c.JSON(400, gin.H{"message": "Invalid course", "errors": fieldErrors})
+		return
+	}
+	if req.Capacity != nil && *req.Capacity == 0 {
+		req.Capacity = nil
+	}
+	if req.TermID != nil {
+		if _, ok := findOpenTerm(c, "create course", *req.TermID); !ok {
+			return
+		}
+	}
 	course := models.Course{}
 	err := database.DB.Where("code = ?", req.Code).First(&course).Error
 	if err == nil {
@@ -37,50 +62,266 @@ func CreateCourse(c *gin.Context) {
 		c.JSON(500, gin.H{"message": "database error"})
 		return
 	}
+	joinCode, err := models.NewJoinCode()
+	if err != nil {
+		log.Println("create course error: failed to create join code")
+		c.JSON(500, gin.H{"message": "Failed to create course"})
+		return
+	}
 	course = models.Course{
-		Name:        req.Name,
-		Code:        req.Code,
-		ProfessorID: c.GetUint("userID"),
-		CreatedAt:   time.Now(),
+		Name:             req.Name,
+		Code:             req.Code,
+		ProfessorID:      c.GetUint("userID"),
+		JoinCode:         joinCode,
+		RequiresApproval: req.RequiresApproval,
+		Capacity:         req.Capacity,
+		TermID:           req.TermID,
+		CreatedAt:        time.Now(),
 	}
 	if err := database.DB.Create(&course).Error; err != nil {
+		if isUniqueViolation(err) {
+			log.Println("create course error: course already exists")
+			c.JSON(400, gin.H{"message": "Course already exists"})
+			return
+		}
 		log.Println("create course error: failed to create course")
 		c.JSON(400, gin.H{"message": "Failed to create course"})
 		return
 	}
 	log.Println("create course success: course created")
 	c.JSON(201, gin.H{
-		"id":          course.ID,
-		"name":        course.Name,
-		"code":        course.Code,
-		"professorID": course.ProfessorID,
-		"createdAt":   course.CreatedAt,
+		"id":               course.ID,
+		"name":             course.Name,
+		"code":             course.Code,
+		"professorID":      course.ProfessorID,
+		"joinCode":         course.JoinCode,
+		"requiresApproval": course.RequiresApproval,
+		"capacity":         course.Capacity,
+		"termId":           course.TermID,
+		"createdAt":        course.CreatedAt,
 	})
 }
 
 func DeleteCourse(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 64)
+	course := c.MustGet("course").(models.Course)
+	deleted, err := trashCourse(course.ID)
 	if err != nil {
-		log.Println("delete course error: invalid course ID")
-		c.JSON(400, gin.H{"message": "Invalid course ID"})
-		return
-	}
-	result := database.DB.Delete(&models.Course{}, id)
-	if result.Error != nil {
-		log.Println("delete course error: failed to delete course")
+		log.Println("delete course error: failed to delete course", err)
 		c.JSON(500, gin.H{"message": "Failed to delete course"})
 		return
 	}
-	if result.RowsAffected == 0 {
+	if !deleted {
 		log.Println("delete course error: course not found")
 		c.JSON(404, gin.H{"message": "Course not found"})
 		return
 	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.delete",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+	})
 	log.Println("delete course success: course deleted")
 	c.JSON(200, gin.H{"message": "Course deleted successfully"})
 }
 
+var errJoinCodeUsedUp = errors.New("join code used up")
+
+// useJoinCode counts a use of the join code that was used, the section's
+// when sectionID is set and the course's otherwise, only while it still has
+// uses left, so concurrent joins can't exceed the limit.
+func useJoinCode(tx *gorm.DB, courseID uint, sectionID *uint) error {
+	query := tx.Model(&models.Course{}).Where("id = ?", courseID)
+	if sectionID != nil {
+		query = tx.Model(&models.Section{}).Where("id = ?", *sectionID)
+	}
+	result := query.
+		Where("join_code_max_uses IS NULL OR join_code_uses < join_code_max_uses").
+		UpdateColumn("join_code_uses", gorm.Expr("join_code_uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errJoinCodeUsedUp
+	}
+	return nil
+}
+
+type updateCourseRequest struct {
+	Name             *string `json:"name"`
+	Code             *string `json:"code"`
+	RequiresApproval *bool   `json:"requiresApproval"`
+	Capacity         *int    `json:"capacity"`
+	TermID           *uint   `json:"termId"`
+}
+
+var courseCodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]*$`)
+
+// validateCourseFields trims the name and code in place and returns an error
+// message per invalid field. Nil fields are left out, so creating and
+// updating a course share the same rules.
+func validateCourseFields(name *string, code *string, capacity *int) map[string]string {
+	fieldErrors := map[string]string{}
+	if name != nil {
+		*name = strings.TrimSpace(*name)
+		switch {
+		case *name == "":
+			fieldErrors["name"] = "Name cannot be empty"
+		case utf8.RuneCountInString(*name) > 200:
+			fieldErrors["name"] = "Name must be at most 200 characters"
+		case strings.ContainsFunc(*name, unicode.IsControl):
+			fieldErrors["name"] = "Name must be a single line of text"
+		}
+	}
+	if code != nil {
+		*code = strings.TrimSpace(*code)
+		switch {
+		case *code == "":
+			fieldErrors["code"] = "Code cannot be empty"
+		case len(*code) > 32:
+			fieldErrors["code"] = "Code must be at most 32 characters"
+		case strings.ContainsFunc(*code, unicode.IsControl):
+			fieldErrors["code"] = "Code must be a single line of text"
+		case !courseCodePattern.MatchString(*code):
+			fieldErrors["code"] = "Code may only contain letters, digits, spaces, '.', '_' and '-'"
+		}
+	}
+	if capacity != nil && *capacity < 0 {
+		fieldErrors["capacity"] = "Capacity cannot be negative"
+	}
+	return fieldErrors
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func UpdateCourse(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	var req updateCourseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Println("update course error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	if req.Name == nil && req.Code == nil && req.RequiresApproval == nil && req.Capacity == nil && req.TermID == nil {
+		log.Println("update course error: no fields to update")
+		c.JSON(400, gin.H{"message": "No fields to update"})
+		return
+	}
+	if fieldErrors := validateCourseFields(req.Name, req.Code, req.Capacity); len(fieldErrors) > 0 {
+		log.Println("update course error: invalid fields")
+		c.JSON(400, gin.H{"message": "Invalid course", "errors": fieldErrors})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil && *req.Name != course.Name {
+		updates["name"] = *req.Name
+	}
+	if req.Code != nil && *req.Code != course.Code {
+		// Soft-deleted courses are excluded by the default scope, so their
+		// codes can be reused.
+		var count int64
+		err := database.DB.Model(&models.Course{}).Where("code = ? AND id <> ?", *req.Code, course.ID).Count(&count).Error
+		if err != nil {
+			log.Println("update course error: database error")
+			c.JSON(500, gin.H{"message": "database error"})
+			return
+		}
+		if count > 0 {
+			log.Println("update course error: code already in use")
+			c.JSON(409, gin.H{"message": "Course code already in use", "errors": gin.H{"code": "Course code already in use"}})
+			return
+		}
+		updates["code"] = *req.Code
+	}
+	if req.RequiresApproval != nil && *req.RequiresApproval != course.RequiresApproval {
+		updates["requires_approval"] = *req.RequiresApproval
+	}
+	if req.Capacity != nil {
+		// A capacity of 0 removes the limit.
+		switch {
+		case *req.Capacity == 0 && course.Capacity != nil:
+			updates["capacity"] = nil
+		case *req.Capacity > 0 && (course.Capacity == nil || *course.Capacity != *req.Capacity):
+			updates["capacity"] = *req.Capacity
+		}
+	}
+	if req.TermID != nil {
+		// A termId of 0 takes the course out of its term.
+		switch {
+		case *req.TermID == 0 && course.TermID != nil:
+			updates["term_id"] = nil
+		case *req.TermID != 0 && (course.TermID == nil || *course.TermID != *req.TermID):
+			if _, ok := findOpenTerm(c, "update course", *req.TermID); !ok {
+				return
+			}
+			updates["term_id"] = *req.TermID
+		}
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(&course).Updates(updates).Error; err != nil {
+			if isUniqueViolation(err) {
+				log.Println("update course error: code already in use")
+				c.JSON(409, gin.H{"message": "Course code already in use", "errors": gin.H{"code": "Course code already in use"}})
+				return
+			}
+			log.Println("update course error: failed to update course", err)
+			c.JSON(500, gin.H{"message": "Failed to update course"})
+			return
+		}
+		if _, ok := updates["capacity"]; ok {
+			var promoted []models.WaitlistEntry
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				var err error
+				promoted, err = promoteWaitlist(tx, course.ID)
+				return err
+			})
+			if err != nil {
+				log.Println("update course error: failed to promote waitlist", err)
+			}
+			notifyPromoted(course, promoted)
+		}
+		if err := database.DB.First(&course, course.ID).Error; err != nil {
+			log.Println("update course error: failed to reload course")
+			c.JSON(500, gin.H{"message": "Failed to get course"})
+			return
+		}
+		audit.Record(models.AuditEvent{
+			ActorID:    c.GetUint("userID"),
+			Action:     "course.update",
+			TargetType: "course",
+			TargetID:   course.ID,
+			IP:         c.ClientIP(),
+			Details:    fmt.Sprintf("fields=%v", mapKeys(updates)),
+		})
+	}
+	log.Println("update course success: course updated")
+	c.JSON(200, gin.H{
+		"id":               course.ID,
+		"name":             course.Name,
+		"code":             course.Code,
+		"professorID":      course.ProfessorID,
+		"requiresApproval": course.RequiresApproval,
+		"capacity":         course.Capacity,
+		"termId":           course.TermID,
+		"createdAt":        course.CreatedAt,
+		"updatedAt":        course.UpdatedAt,
+	})
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func GetCourseByUserID(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -88,8 +329,19 @@ func GetCourseByUserID(c *gin.Context) {
 		c.JSON(401, gin.H{"message": "Missing userID"})
 		return
 	}
+	scope, _, err := courseListScope(c)
+	if err != nil {
+		log.Println("get course by userID error: invalid filter")
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
 	courses := []models.Course{}
-	if err := database.DB.Where("professor_id = ?", userID).Find(&courses).Error; err != nil {
+	staffCourseIDs := database.DB.Model(&models.CourseRoleAssignment{}).Select("course_id").Where("user_id = ?", userID)
+	err = database.DB.
+		Where(database.DB.Where("professor_id = ?", userID).Or("id IN (?)", staffCourseIDs)).
+		Scopes(scope).
+		Find(&courses).Error
+	if err != nil {
 		log.Println("get course by userID error: failed to get courses")
 		c.JSON(400, gin.H{"message": "Failed to get courses"})
 		return
@@ -109,9 +361,28 @@ func JoinCourse(c *gin.Context) {
 		c.JSON(400, gin.H{"message": "Invalid request"})
 		return
 	}
+	userID := c.GetUint("userID")
+	// Join codes are short, so cap how fast one user can guess them.
+	allowed, err := ratelimit.Allow(fmt.Sprintf("join_course:%d", userID), 20, time.Hour)
+	if err != nil {
+		log.Println("join course error: failed to check rate limit", err)
+		c.JSON(500, gin.H{"message": "Failed to join course"})
+		return
+	}
+	if !allowed {
+		log.Println("join course error: rate limited")
+		c.JSON(429, gin.H{"message": "Too many attempts, try again later"})
+		return
+	}
 
-	course := models.Course{}
-	if err := database.DB.Where("code=?", req.Code).First(&course).Error; err != nil {
+	code := models.NormalizeJoinCode(req.Code)
+	if code == "" {
+		log.Println("join course error: code is required")
+		c.JSON(400, gin.H{"message": "Code is required"})
+		return
+	}
+	course, section, err := findByJoinCode(code)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Println("join course error: course not found")
 			c.JSON(404, gin.H{"message": "Course not found"})
@@ -121,63 +392,157 @@ func JoinCourse(c *gin.Context) {
 		c.JSON(500, gin.H{"message": "Failed to get course"})
 		return
 	}
+	if course.Archived() {
+		log.Println("join course error: course archived")
+		c.JSON(410, gin.H{"message": "Course is archived"})
+		return
+	}
+	var sectionID *uint
+	usable := course.JoinCodeUsable(time.Now())
+	if section != nil {
+		sectionID = &section.ID
+		usable = section.JoinCodeUsable(time.Now())
+	}
+	if !usable {
+		log.Println("join course error: join code expired or used up")
+		c.JSON(410, gin.H{"message": "Join code is no longer valid"})
+		return
+	}
+	block, err := courseBlock(course.ID, userID)
+	if err != nil {
+		log.Println("join course error: failed to check block")
+		c.JSON(500, gin.H{"message": "Failed to join course"})
+		return
+	}
+	if block != nil {
+		log.Println("join course error: blocked from course")
+		c.JSON(403, gin.H{"message": "You have been blocked from this course", "reason": block.Reason})
+		return
+	}
 	log.Println("join course success: course found")
-	existingEnrollment := models.Enrollment{}
-	err := database.DB.Where("course_id = ? AND user_id = ?", course.ID, c.GetUint("userID")).First(&existingEnrollment).Error
+	enrolled, err := isEnrolled(database.DB, course.ID, userID)
 	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Println("join course error: failed to check existing enrollment")
-			c.JSON(500, gin.H{"message": "Failed to check existing enrollment"})
-			return
-		}
+		log.Println("join course error: failed to check existing enrollment")
+		c.JSON(500, gin.H{"message": "Failed to check existing enrollment"})
+		return
 	}
-	if existingEnrollment.ID != 0 {
+	if enrolled {
 		log.Println("join course error: already enrolled in this course")
 		c.JSON(400, gin.H{"message": "Already enrolled in this course"})
 		return
 	}
-	enrollment := models.Enrollment{
-		UserID:    c.GetUint("userID"),
-		CourseID:  course.ID,
-		CreatedAt: time.Now(),
+	waitlisted, err := isWaitlisted(database.DB, course.ID, userID)
+	if err != nil {
+		log.Println("join course error: failed to check waitlist")
+		c.JSON(500, gin.H{"message": "Failed to check existing enrollment"})
+		return
+	}
+	if waitlisted {
+		log.Println("join course error: already on the waitlist")
+		c.JSON(400, gin.H{"message": "Already on the waitlist for this course"})
+		return
+	}
+	if course.RequiresApproval {
+		requestJoin(c, course, sectionID, userID)
+		return
 	}
-	if err := database.DB.Create(&enrollment).Error; err != nil {
+	var status string
+	var position int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := useJoinCode(tx, course.ID, sectionID); err != nil {
+			return err
+		}
+		var err error
+		status, position, err = enrollOrWaitlist(tx, course.ID, sectionID, userID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errJoinCodeUsedUp) {
+			log.Println("join course error: join code used up")
+			c.JSON(410, gin.H{"message": "Join code is no longer valid"})
+			return
+		}
+		if errors.Is(err, errAlreadyEnrolled) {
+			log.Println("join course error: already enrolled in this course")
+			c.JSON(400, gin.H{"message": "Already enrolled in this course"})
+			return
+		}
+		if errors.Is(err, errAlreadyWaitlisted) {
+			log.Println("join course error: already on the waitlist")
+			c.JSON(400, gin.H{"message": "Already on the waitlist for this course"})
+			return
+		}
+		if errors.Is(err, errSectionFull) {
+			log.Println("join course error: section full")
+			c.JSON(409, gin.H{"message": "Section is full"})
+			return
+		}
 		log.Println("join course error: failed to join course")
 		c.JSON(500, gin.H{"message": "Failed to join course"})
 		return
 	}
+	if status == joinWaitlisted {
+		log.Println("join course success: waitlisted")
+		c.JSON(202, gin.H{
+			"message":   "Course is full, added to the waitlist",
+			"courseId":  course.ID,
+			"sectionId": sectionID,
+			"status":    status,
+			"position":  position,
+		})
+		return
+	}
 	log.Println("join course success: joined course")
 	c.JSON(200, gin.H{
-		"message":  "Joined course successfully",
-		"courseId": course.ID})
+		"message":   "Joined course successfully",
+		"courseId":  course.ID,
+		"sectionId": sectionID,
+		"status":    status,
+	})
 }
 
+// LeaveCourse drops the student from the course, or from its waitlist if
+// they haven't got a seat yet. A freed seat goes to the next student in line.
 func LeaveCourse(c *gin.Context) {
-	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		log.Println("leave course error: invalid course ID")
-		c.JSON(400, gin.H{"message": "Invalid course ID"})
-		return
-	}
+	course := c.MustGet("course").(models.Course)
 	userID := c.GetUint("userID")
 	var enrollment models.Enrollment
-	err = database.DB.Where("course_id = ? AND user_id = ?", courseID, userID).First(&enrollment).Error
+	err := database.DB.Where("course_id = ? AND user_id = ? AND status = ?", course.ID, userID, models.EnrollmentActive).First(&enrollment).Error
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("leave course error: failed to get enrollment")
+			c.JSON(500, gin.H{"message": "Failed to get enrollment"})
+			return
+		}
+		result := database.DB.Where("course_id = ? AND user_id = ?", course.ID, userID).Delete(&models.WaitlistEntry{})
+		if result.Error != nil {
+			log.Println("leave course error: failed to leave waitlist")
+			c.JSON(500, gin.H{"message": "Failed to leave course"})
+			return
+		}
+		if result.RowsAffected == 0 {
 			log.Println("leave course error: enrollment not found")
 			c.JSON(400, gin.H{"message": "Enrollment not found"})
 			return
 		}
-		log.Println("leave course error: failed to get enrollment")
-		c.JSON(500, gin.H{"message": "Failed to get enrollment"})
+		log.Println("leave course success: left waitlist")
+		c.JSON(200, gin.H{"message": "Left the waitlist successfully"})
 		return
 	}
-	result := database.DB.Delete(&enrollment)
-	if result.Error != nil {
-		log.Println("leave course error: failed to leave course")
+	var promoted []models.WaitlistEntry
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := endEnrollment(tx, enrollment.ID, models.EnrollmentDropped); err != nil {
+			return err
+		}
+		promoted, err = promoteWaitlist(tx, course.ID)
+		return err
+	})
+	if err != nil {
+		log.Println("leave course error: failed to leave course", err)
 		c.JSON(500, gin.H{"message": "Failed to leave course"})
 		return
 	}
+	notifyPromoted(course, promoted)
 	log.Println("leave course success: unenrolled course")
 	c.JSON(200, gin.H{"message": "Unenrolled course successfully"})
 }
@@ -189,18 +554,88 @@ func GetEnrollmentsByStudentID(c *gin.Context) {
 		c.JSON(401, gin.H{"message": "Missing userID"})
 		return
 	}
+	scope, state, err := courseListScope(c)
+	if err != nil {
+		log.Println("get enrollments by studentID error: invalid filter")
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+	// Enrollments in archived courses were completed when the course was
+	// archived.
+	statuses := []string{models.EnrollmentActive}
+	if state != "active" {
+		statuses = append(statuses, models.EnrollmentCompleted)
+	}
 	courses := []models.Course{}
-	err := database.DB.
+	err = database.DB.
 		Joins("JOIN enrollments ON enrollments.course_id = courses.id").
-		Where("enrollments.user_id = ?", studentID).
+		Where("enrollments.user_id = ? AND enrollments.status IN ?", studentID, statuses).
+		Scopes(scope).
 		Find(&courses).Error
 	if err != nil {
 		log.Println("get enrollments by studentID error: failed to get courses")
 		c.JSON(500, gin.H{"message": "Failed to get courses"})
 		return
 	}
+	memberships := []models.Enrollment{}
+	err = database.DB.Preload("Section").
+		Where("user_id = ? AND status IN ? AND section_id IS NOT NULL", studentID, statuses).
+		Find(&memberships).Error
+	if err != nil {
+		log.Println("get enrollments by studentID error: failed to get sections")
+		c.JSON(500, gin.H{"message": "Failed to get sections"})
+		return
+	}
+	sections := map[uint]gin.H{}
+	for _, membership := range memberships {
+		sections[membership.CourseID] = sectionSummary(membership.Section)
+	}
+	requests := []models.JoinRequest{}
+	err = database.DB.Preload("Course").
+		Where("user_id = ? AND status = ?", studentID, models.JoinRequestPending).
+		Order("created_at").
+		Find(&requests).Error
+	if err != nil {
+		log.Println("get enrollments by studentID error: failed to get join requests")
+		c.JSON(500, gin.H{"message": "Failed to get join requests"})
+		return
+	}
+	entries := []models.WaitlistEntry{}
+	err = database.DB.Preload("Course").Where("user_id = ?", studentID).Order("created_at").Find(&entries).Error
+	if err != nil {
+		log.Println("get enrollments by studentID error: failed to get waitlist")
+		c.JSON(500, gin.H{"message": "Failed to get waitlist"})
+		return
+	}
+	waitlisted := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		position, err := waitlistPosition(entry)
+		if err != nil {
+			log.Println("get enrollments by studentID error: failed to get waitlist position")
+			c.JSON(500, gin.H{"message": "Failed to get waitlist"})
+			return
+		}
+		waitlisted = append(waitlisted, gin.H{
+			"course":       entry.Course,
+			"position":     position,
+			"waitlistedAt": entry.CreatedAt,
+		})
+	}
+	pending := make([]gin.H, 0, len(requests))
+	for _, request := range requests {
+		pending = append(pending, gin.H{
+			"requestId":   request.ID,
+			"course":      request.Course,
+			"requestedAt": request.CreatedAt,
+		})
+	}
 	log.Println("get enrollments by studentID success: courses found")
-	c.JSON(200, gin.H{"courses": courses})
+	c.JSON(200, gin.H{
+		"courses":    courses,
+		"sections":   sections,
+		"pending":    pending,
+		"waitlisted": waitlisted,
+	})
 }
 
 func DeleteCourseByID(c *gin.Context) {
@@ -210,13 +645,13 @@ func DeleteCourseByID(c *gin.Context) {
 		c.JSON(400, gin.H{"message": "Invalid course ID"})
 		return
 	}
-	result := database.DB.Delete(&models.Course{}, id)
-	if result.Error != nil {
-		log.Println("delete course by ID error: failed to delete course")
+	deleted, err := trashCourse(uint(id))
+	if err != nil {
+		log.Println("delete course by ID error: failed to delete course", err)
 		c.JSON(500, gin.H{"message": "Failed to delete course"})
 		return
 	}
-	if result.RowsAffected == 0 {
+	if !deleted {
 		log.Println("delete course by ID error: course not found")
 		c.JSON(404, gin.H{"message": "Course not found"})
 		return
@@ -226,16 +661,9 @@ func DeleteCourseByID(c *gin.Context) {
 }
 
 func GetCourseDeleteInfo(c *gin.Context) {
-	courseID := c.Param("id")
-	var course models.Course
-	if err := database.DB.First(&course, courseID).Error; err != nil {
-		c.JSON(404, gin.H{"message": "Course not found"})
-		return
-	}
+	course := c.MustGet("course").(models.Course)
 
-	var count int64
-	database.DB.
-		Model(&models.Enrollment{}).Where("course_id = ?", courseID).Count(&count)
+	count, _ := countActiveEnrollments(database.DB, course.ID)
 
 	c.JSON(200, gin.H{
 		"courseName":      course.Name,
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidateCourseFields(t *testing.T) {
+	text := func(s string) *string {
+		return &s
+	}
+	number := func(n int) *int {
+		return &n
+	}
+	tests := []struct {
+		name       string
+		courseName *string
+		code       *string
+		capacity   *int
+		wantFields []string
+	}{
+		{name: "nothing to check"},
+		{name: "valid", courseName: text("Intro to Programming"), code: text("CS 101-A"), capacity: number(30)},
+		{name: "200 multibyte characters", courseName: text(strings.Repeat("é", 200))},
+		{name: "201 characters", courseName: text(strings.Repeat("a", 201)), wantFields: []string{"name"}},
+		{name: "blank name", courseName: text("   "), wantFields: []string{"name"}},
+		{name: "newline in name", courseName: text("Intro\r\nBcc: everyone@example.com"), wantFields: []string{"name"}},
+		{name: "tab in name", courseName: text("Intro\tProgramming"), wantFields: []string{"name"}},
+		{name: "blank code", code: text(" "), wantFields: []string{"code"}},
+		{name: "newline in code", code: text("CS\n101"), wantFields: []string{"code"}},
+		{name: "symbols in code", code: text("CS#101"), wantFields: []string{"code"}},
+		{name: "long code", code: text(strings.Repeat("A", 33)), wantFields: []string{"code"}},
+		{name: "negative capacity", capacity: number(-1), wantFields: []string{"capacity"}},
+		{name: "everything wrong", courseName: text(""), code: text(""), capacity: number(-1), wantFields: []string{"capacity", "code", "name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldErrors := validateCourseFields(tt.courseName, tt.code, tt.capacity)
+			got := []string{}
+			for field := range fieldErrors {
+				got = append(got, field)
+			}
+			want := tt.wantFields
+			if want == nil {
+				want = []string{}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("invalid fields %v, want %v (%v)", got, want, fieldErrors)
+			}
+		})
+	}
+}
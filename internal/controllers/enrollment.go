@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"conductor_backend/internal/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errAlreadyEnrolled   = errors.New("already enrolled")
+	errAlreadyWaitlisted = errors.New("already waitlisted")
+	errSectionFull       = errors.New("section full")
+)
+
+func isEnrolled(tx *gorm.DB, courseID uint, userID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Enrollment{}).
+		Where("course_id = ? AND user_id = ? AND status = ?", courseID, userID, models.EnrollmentActive).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func isWaitlisted(tx *gorm.DB, courseID uint, userID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.WaitlistEntry{}).Where("course_id = ? AND user_id = ?", courseID, userID).Count(&count).Error
+	return count > 0, err
+}
+
+func countActiveEnrollments(tx *gorm.DB, courseID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&models.Enrollment{}).
+		Where("course_id = ? AND status = ?", courseID, models.EnrollmentActive).
+		Count(&count).Error
+	return count, err
+}
+
+// sectionFull reports whether the section has no free seat. Callers hold the
+// course lock, which also covers the course's sections.
+func sectionFull(tx *gorm.DB, sectionID uint) (bool, error) {
+	section := models.Section{}
+	if err := tx.Select("id", "capacity").First(&section, sectionID).Error; err != nil {
+		return false, err
+	}
+	if section.Capacity == nil {
+		return false, nil
+	}
+	var count int64
+	err := tx.Model(&models.Enrollment{}).
+		Where("section_id = ? AND status = ?", sectionID, models.EnrollmentActive).
+		Count(&count).Error
+	return count >= int64(*section.Capacity), err
+}
+
+// activateEnrollment enrolls the user in the course and, if sectionID is
+// set, in that section. A student who was enrolled before gets their old row
+// back, so there is one row per student and course.
+func activateEnrollment(tx *gorm.DB, courseID uint, sectionID *uint, userID uint) error {
+	now := time.Now()
+	enrollment := models.Enrollment{
+		UserID:     userID,
+		CourseID:   courseID,
+		SectionID:  sectionID,
+		Status:     models.EnrollmentActive,
+		CreatedAt:  now,
+		EnrolledAt: now,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "course_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"section_id":  sectionID,
+			"status":      models.EnrollmentActive,
+			"enrolled_at": now,
+			"ended_at":    nil,
+		}),
+	}).Create(&enrollment).Error
+}
+
+// endEnrollment moves an active enrollment to status. It reports false when
+// the enrollment wasn't active anymore.
+func endEnrollment(tx *gorm.DB, enrollmentID uint, status string) (bool, error) {
+	result := tx.Model(&models.Enrollment{}).
+		Where("id = ? AND status = ?", enrollmentID, models.EnrollmentActive).
+		Updates(map[string]interface{}{"status": status, "ended_at": time.Now()})
+	return result.RowsAffected == 1, result.Error
+}
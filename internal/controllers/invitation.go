@@ -0,0 +1,464 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errInvalidInvitation = errors.New("invalid invitation")
+
+func sendInvitationEmail(course models.Course, invitation models.Invitation) error {
+	token, err := auth.IssueInvitationToken(invitation.ID, invitation.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	greeting := "Hi"
+	if invitation.Name != "" {
+		greeting += " " + invitation.Name
+	}
+	return mailer.Send(mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You're invited to join %s", course.Name),
+		Body: fmt.Sprintf(
+			"%s,\n\nYou're invited to join %s (%s) on Conductor. Accept the invitation by %s:\n\n%s\n\n"+
+				"If you don't have an account yet, sign up with this email address and you will be enrolled once you confirm it.\n",
+			greeting, course.Name, course.Code, invitation.ExpiresAt.Format("January 2, 2006"), appLink("/invitations/accept", token),
+		),
+	})
+}
+
+// invitationByToken returns the pending invitation of an accept link token
+// with its course. The course is left empty if it was deleted.
+func invitationByToken(token string) (models.Invitation, error) {
+	id, err := auth.ParseInvitationToken(token)
+	if err != nil {
+		return models.Invitation{}, errInvalidInvitation
+	}
+	invitation := models.Invitation{}
+	err = database.DB.Preload("Course").
+		Where("status = ?", models.InvitationPending).
+		First(&invitation, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && invitation.Expired(time.Now())) {
+		return models.Invitation{}, errInvalidInvitation
+	}
+	return invitation, err
+}
+
+// revokeExpiredInvitation revokes the email's pending invitation to the
+// course if it has expired, so a new one can be sent.
+func revokeExpiredInvitation(tx *gorm.DB, courseID uint, email string) error {
+	now := time.Now()
+	return tx.Model(&models.Invitation{}).
+		Where("course_id = ? AND email = ? AND status = ? AND expires_at <= ?", courseID, email, models.InvitationPending, now).
+		Updates(map[string]interface{}{"status": models.InvitationRevoked, "revoked_at": now}).Error
+}
+
+// acceptInvitation enrolls userID through the invitation, or waitlists them
+// when the course is full, and marks it accepted. Students who are already
+// enrolled or waitlisted just use it up.
+func acceptInvitation(tx *gorm.DB, invitation models.Invitation, userID uint) (string, error) {
+	status, _, err := enrollOrWaitlist(tx, invitation.CourseID, invitation.SectionID, userID)
+	if errors.Is(err, errAlreadyEnrolled) {
+		status, err = joinEnrolled, nil
+	} else if errors.Is(err, errAlreadyWaitlisted) {
+		status, err = joinWaitlisted, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	result := tx.Model(&models.Invitation{}).
+		Where("id = ? AND status = ?", invitation.ID, models.InvitationPending).
+		Updates(map[string]interface{}{
+			"status":      models.InvitationAccepted,
+			"accepted_by": userID,
+			"accepted_at": time.Now(),
+		})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", errInvalidInvitation
+	}
+	return status, approvePendingJoinRequest(tx, invitation.CourseID, userID, invitation.InvitedBy, "Invitation accepted")
+}
+
+// acceptPendingInvitations enrolls a student whose email was just verified
+// in every course it was invited to and returns those course IDs. An
+// invitation that can't be accepted stays pending. Other users, and students
+// who haven't verified their email yet, join nothing.
+func acceptPendingInvitations(user models.User) []uint {
+	if user.Role != models.RoleStudent || !user.IsVerified() {
+		return nil
+	}
+	invitations := []models.Invitation{}
+	err := database.DB.Preload("Course").
+		Where("email = ? AND status = ? AND expires_at > ?", strings.ToLower(user.Email), models.InvitationPending, time.Now()).
+		Order("id").
+		Find(&invitations).Error
+	if err != nil {
+		log.Println("accept invitations error: failed to get invitations", err)
+		return nil
+	}
+	joined := []uint{}
+	for _, invitation := range invitations {
+		if invitation.Course.ID == 0 || invitation.Course.Archived() {
+			continue
+		}
+		staff, err := isCourseStaff(database.DB, invitation.Course, user.ID)
+		if err != nil {
+			log.Println("accept invitations error: failed to check staff", err)
+			continue
+		}
+		if staff {
+			continue
+		}
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			_, err := acceptInvitation(tx, invitation, user.ID)
+			return err
+		})
+		if err != nil {
+			log.Println("accept invitations error: failed to accept invitation", err)
+			continue
+		}
+		joined = append(joined, invitation.CourseID)
+	}
+	return joined
+}
+
+func invitationResponse(invitation models.Invitation) gin.H {
+	return gin.H{
+		"id":         invitation.ID,
+		"email":      invitation.Email,
+		"name":       invitation.Name,
+		"sectionId":  invitation.SectionID,
+		"status":     invitation.Status,
+		"invitedBy":  invitation.InvitedBy,
+		"createdAt":  invitation.CreatedAt,
+		"expiresAt":  invitation.ExpiresAt,
+		"expired":    invitation.Status == models.InvitationPending && invitation.Expired(time.Now()),
+		"acceptedBy": invitation.AcceptedBy,
+		"acceptedAt": invitation.AcceptedAt,
+		"revokedAt":  invitation.RevokedAt,
+	}
+}
+
+type createInvitationRequest struct {
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	SectionID *uint  `json:"sectionId"`
+}
+
+// CreateInvitation emails an invitation to join the course, placing the
+// student in sectionId if it is set.
+func CreateInvitation(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	var req createInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Println("create invitation error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	req.Name = strings.TrimSpace(req.Name)
+	if !validEmail(req.Email) {
+		log.Println("create invitation error: invalid email")
+		c.JSON(400, gin.H{"message": "Invalid email address"})
+		return
+	}
+	email := strings.ToLower(req.Email)
+	if req.SectionID != nil {
+		var count int64
+		if err := database.DB.Model(&models.Section{}).Where("id = ? AND course_id = ?", *req.SectionID, course.ID).Count(&count).Error; err != nil {
+			log.Println("create invitation error: failed to get section")
+			c.JSON(500, gin.H{"message": "Failed to get section"})
+			return
+		}
+		if count == 0 {
+			log.Println("create invitation error: section not found")
+			c.JSON(404, gin.H{"message": "Section not found"})
+			return
+		}
+	}
+	user := models.User{}
+	err := database.DB.Where("LOWER(email) = ?", email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("create invitation error: failed to get user")
+		c.JSON(500, gin.H{"message": "Failed to get user"})
+		return
+	}
+	if err == nil {
+		staff, err := isCourseStaff(database.DB, course, user.ID)
+		if err != nil {
+			log.Println("create invitation error: failed to check staff")
+			c.JSON(500, gin.H{"message": "Failed to check staff"})
+			return
+		}
+		if staff {
+			log.Println("create invitation error: user is course staff")
+			c.JSON(409, gin.H{"message": "Course staff can't be enrolled"})
+			return
+		}
+		enrolled, err := isEnrolled(database.DB, course.ID, user.ID)
+		if err != nil {
+			log.Println("create invitation error: failed to check enrollment")
+			c.JSON(500, gin.H{"message": "Failed to check enrollment"})
+			return
+		}
+		if enrolled {
+			log.Println("create invitation error: already enrolled")
+			c.JSON(409, gin.H{"message": "Student is already enrolled in this course"})
+			return
+		}
+		block, err := courseBlock(course.ID, user.ID)
+		if err != nil {
+			log.Println("create invitation error: failed to check block")
+			c.JSON(500, gin.H{"message": "Failed to check block"})
+			return
+		}
+		if block != nil {
+			log.Println("create invitation error: student blocked")
+			c.JSON(409, gin.H{"message": "Student is blocked from this course"})
+			return
+		}
+	}
+	if err := revokeExpiredInvitation(database.DB, course.ID, email); err != nil {
+		log.Println("create invitation error: failed to revoke expired invitation")
+		c.JSON(500, gin.H{"message": "Failed to create invitation"})
+		return
+	}
+	now := time.Now()
+	invitation := models.Invitation{
+		CourseID:  course.ID,
+		Email:     email,
+		Name:      req.Name,
+		SectionID: req.SectionID,
+		Status:    models.InvitationPending,
+		InvitedBy: c.GetUint("userID"),
+		CreatedAt: now,
+		ExpiresAt: now.Add(models.InvitationTTL),
+	}
+	if err := database.DB.Create(&invitation).Error; err != nil {
+		if isUniqueViolation(err) {
+			log.Println("create invitation error: already invited")
+			c.JSON(409, gin.H{"message": "Email already has a pending invitation"})
+			return
+		}
+		log.Println("create invitation error: failed to create invitation", err)
+		c.JSON(500, gin.H{"message": "Failed to create invitation"})
+		return
+	}
+	if err := sendInvitationEmail(course, invitation); err != nil {
+		log.Println("create invitation error: failed to send email", err)
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    invitation.InvitedBy,
+		Action:     "course.invitation.create",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("invitation=%d email=%s", invitation.ID, invitation.Email),
+	})
+	log.Println("create invitation success: invitation sent")
+	c.JSON(201, invitationResponse(invitation))
+}
+
+// ListInvitations lists the course's invitations, newest first. status is
+// pending (default), accepted, revoked or all.
+func ListInvitations(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	status := c.DefaultQuery("status", models.InvitationPending)
+	query := database.DB.Where("course_id = ?", course.ID)
+	switch status {
+	case models.InvitationPending, models.InvitationAccepted, models.InvitationRevoked:
+		query = query.Where("status = ?", status)
+	case "all":
+	default:
+		log.Println("list invitations error: invalid status")
+		c.JSON(400, gin.H{"message": "status must be pending, accepted, revoked or all"})
+		return
+	}
+	invitations := []models.Invitation{}
+	if err := query.Order("created_at DESC").Find(&invitations).Error; err != nil {
+		log.Println("list invitations error: failed to get invitations")
+		c.JSON(500, gin.H{"message": "Failed to get invitations"})
+		return
+	}
+	result := make([]gin.H, 0, len(invitations))
+	for _, invitation := range invitations {
+		result = append(result, invitationResponse(invitation))
+	}
+	log.Println("list invitations success: invitations found")
+	c.JSON(200, gin.H{"invitations": result})
+}
+
+func RevokeInvitation(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	invitationID, err := strconv.ParseUint(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		log.Println("revoke invitation error: invalid invitation ID")
+		c.JSON(400, gin.H{"message": "Invalid invitation ID"})
+		return
+	}
+	result := database.DB.Model(&models.Invitation{}).
+		Where("id = ? AND course_id = ? AND status = ?", invitationID, course.ID, models.InvitationPending).
+		Updates(map[string]interface{}{"status": models.InvitationRevoked, "revoked_at": time.Now()})
+	if result.Error != nil {
+		log.Println("revoke invitation error: failed to revoke invitation")
+		c.JSON(500, gin.H{"message": "Failed to revoke invitation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		log.Println("revoke invitation error: invitation not found")
+		c.JSON(404, gin.H{"message": "Pending invitation not found"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.invitation.revoke",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("invitation=%d", invitationID),
+	})
+	log.Println("revoke invitation success: invitation revoked")
+	c.JSON(200, gin.H{"message": "Invitation revoked successfully"})
+}
+
+type invitationTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// PreviewInvitation shows what an accept link is for, so the frontend can
+// show it before the user signs in.
+func PreviewInvitation(c *gin.Context) {
+	var req invitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		log.Println("preview invitation error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	invitation, err := invitationByToken(req.Token)
+	if err != nil && !errors.Is(err, errInvalidInvitation) {
+		log.Println("preview invitation error: failed to get invitation")
+		c.JSON(500, gin.H{"message": "Failed to get invitation"})
+		return
+	}
+	if err != nil || invitation.Course.ID == 0 {
+		log.Println("preview invitation error: invalid or expired invitation")
+		c.JSON(400, gin.H{"message": "Invalid or expired invitation"})
+		return
+	}
+	log.Println("preview invitation success: invitation found")
+	c.JSON(200, gin.H{
+		"email":      invitation.Email,
+		"courseName": invitation.Course.Name,
+		"courseCode": invitation.Course.Code,
+		"expiresAt":  invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitation enrolls the signed-in user through an accept link. The
+// link only works for the email it was sent to.
+func AcceptInvitation(c *gin.Context) {
+	var req invitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		log.Println("accept invitation error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	userID := c.GetUint("userID")
+	invitation, err := invitationByToken(req.Token)
+	if err != nil {
+		if errors.Is(err, errInvalidInvitation) {
+			log.Println("accept invitation error: invalid or expired invitation")
+			c.JSON(400, gin.H{"message": "Invalid or expired invitation"})
+			return
+		}
+		log.Println("accept invitation error: failed to get invitation")
+		c.JSON(500, gin.H{"message": "Failed to get invitation"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		log.Println("accept invitation error: failed to get user")
+		c.JSON(500, gin.H{"message": "Failed to get user"})
+		return
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		log.Println("accept invitation error: email mismatch")
+		c.JSON(403, gin.H{"message": "This invitation was sent to a different email address"})
+		return
+	}
+	course := invitation.Course
+	if course.ID == 0 {
+		log.Println("accept invitation error: course deleted")
+		c.JSON(410, gin.H{"message": "Course no longer exists"})
+		return
+	}
+	if course.Archived() {
+		log.Println("accept invitation error: course archived")
+		c.JSON(410, gin.H{"message": "Course is archived"})
+		return
+	}
+	staff, err := isCourseStaff(database.DB, course, userID)
+	if err != nil {
+		log.Println("accept invitation error: failed to check staff")
+		c.JSON(500, gin.H{"message": "Failed to accept invitation"})
+		return
+	}
+	if staff {
+		log.Println("accept invitation error: user is course staff")
+		c.JSON(409, gin.H{"message": "Course staff can't be enrolled"})
+		return
+	}
+	block, err := courseBlock(course.ID, userID)
+	if err != nil {
+		log.Println("accept invitation error: failed to check block")
+		c.JSON(500, gin.H{"message": "Failed to accept invitation"})
+		return
+	}
+	if block != nil {
+		log.Println("accept invitation error: blocked from course")
+		c.JSON(403, gin.H{"message": "You have been blocked from this course", "reason": block.Reason})
+		return
+	}
+	var status string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		accepted, err := acceptInvitation(tx, invitation, userID)
+		status = accepted
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidInvitation) {
+			log.Println("accept invitation error: invitation already used")
+			c.JSON(400, gin.H{"message": "Invalid or expired invitation"})
+			return
+		}
+		if errors.Is(err, errSectionFull) {
+			log.Println("accept invitation error: section full")
+			c.JSON(409, gin.H{"message": "Section is full"})
+			return
+		}
+		log.Println("accept invitation error: failed to accept invitation", err)
+		c.JSON(500, gin.H{"message": "Failed to accept invitation"})
+		return
+	}
+	log.Println("accept invitation success: invitation accepted")
+	c.JSON(200, gin.H{
+		"message":  "Invitation accepted",
+		"courseId": course.ID,
+		"status":   status,
+	})
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/models"
+)
+
+func TestInvitationByTokenRejectsBadTokens(t *testing.T) {
+	t.Setenv("JWT_KEYS_DIR", "")
+	t.Setenv("APP_ENV", "dev")
+	if err := auth.LoadKeys(); err != nil {
+		t.Fatalf("load keys: %v", err)
+	}
+	expired, err := auth.IssueInvitationToken(1, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	access, err := auth.IssueAccessToken(models.User{ID: 1}, "session")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	// The token is checked before the database is, which these tests don't
+	// have.
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "empty"},
+		{name: "garbage", token: "not-a-token"},
+		{name: "expired", token: expired},
+		{name: "access token", token: access},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := invitationByToken(tt.token); !errors.Is(err, errInvalidInvitation) {
+				t.Errorf("err = %v, want %v", err, errInvalidInvitation)
+			}
+		})
+	}
+}
+
+func TestAcceptPendingInvitationsNeedsVerifiedStudent(t *testing.T) {
+	now := time.Now()
+	// Only verified students reach the database, which these tests don't
+	// have, so the others must return before touching it.
+	tests := []struct {
+		name string
+		user models.User
+	}{
+		{name: "unverified student", user: models.User{ID: 1, Email: "s@example.com", Role: models.RoleStudent}},
+		{name: "professor", user: models.User{ID: 2, Email: "p@example.com", Role: models.RoleProfessor, VerifiedAt: &now}},
+		{name: "admin", user: models.User{ID: 3, Email: "a@example.com", Role: models.RoleAdmin, VerifiedAt: &now}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if joined := acceptPendingInvitations(tt.user); len(joined) != 0 {
+				t.Errorf("joined %v, want none", joined)
+			}
+		})
+	}
+}
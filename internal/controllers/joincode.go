@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func joinCodeResponse(course models.Course) gin.H {
+	return gin.H{
+		"joinCode":  course.JoinCode,
+		"expiresAt": course.JoinCodeExpiresAt,
+		"maxUses":   course.JoinCodeMaxUses,
+		"uses":      course.JoinCodeUses,
+		"usable":    course.JoinCodeUsable(time.Now()),
+	}
+}
+
+func sectionJoinCodeResponse(section models.Section) gin.H {
+	return gin.H{
+		"joinCode":  section.JoinCode,
+		"expiresAt": section.JoinCodeExpiresAt,
+		"maxUses":   section.JoinCodeMaxUses,
+		"uses":      section.JoinCodeUses,
+		"usable":    section.JoinCodeUsable(time.Now()),
+	}
+}
+
+func GetJoinCode(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	log.Println("get join code success: join code found")
+	c.JSON(200, joinCodeResponse(course))
+}
+
+type regenerateJoinCodeRequest struct {
+	ExpiresInHours *int `json:"expiresInHours"`
+	MaxUses        *int `json:"maxUses"`
+}
+
+// bindJoinCodeLimits reads the optional expiry and use limit of a new join
+// code, answering the request itself when they are invalid.
+func bindJoinCodeLimits(c *gin.Context, action string) (*time.Time, *int, bool) {
+	var req regenerateJoinCodeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Println(action + " error: invalid request")
+			c.JSON(400, gin.H{"message": "Invalid request"})
+			return nil, nil, false
+		}
+	}
+	if req.ExpiresInHours != nil && *req.ExpiresInHours <= 0 {
+		log.Println(action + " error: invalid expiry")
+		c.JSON(400, gin.H{"message": "expiresInHours must be positive"})
+		return nil, nil, false
+	}
+	if req.MaxUses != nil && *req.MaxUses <= 0 {
+		log.Println(action + " error: invalid max uses")
+		c.JSON(400, gin.H{"message": "maxUses must be positive"})
+		return nil, nil, false
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInHours != nil {
+		t := time.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+	return expiresAt, req.MaxUses, true
+}
+
+// joinCodeLimitDetails describes a join code's limits for the audit log.
+func joinCodeLimitDetails(expiresAt *time.Time, maxUses *int) string {
+	details := "expiresAt=never"
+	if expiresAt != nil {
+		details = "expiresAt=" + expiresAt.Format(time.RFC3339)
+	}
+	if maxUses != nil {
+		details += fmt.Sprintf(" maxUses=%d", *maxUses)
+	} else {
+		details += " maxUses=unlimited"
+	}
+	return details
+}
+
+// RegenerateJoinCode replaces the course's join code, which immediately stops
+// the old one from working, and resets its use count.
+func RegenerateJoinCode(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	expiresAt, maxUses, ok := bindJoinCodeLimits(c, "regenerate join code")
+	if !ok {
+		return
+	}
+	code, err := models.NewJoinCode()
+	if err != nil {
+		log.Println("regenerate join code error: failed to create code")
+		c.JSON(500, gin.H{"message": "Failed to create join code"})
+		return
+	}
+	err = database.DB.Model(&course).Updates(map[string]interface{}{
+		"join_code":            code,
+		"join_code_expires_at": expiresAt,
+		"join_code_max_uses":   maxUses,
+		"join_code_uses":       0,
+	}).Error
+	if err != nil {
+		log.Println("regenerate join code error: failed to save course", err)
+		c.JSON(500, gin.H{"message": "Failed to create join code"})
+		return
+	}
+	course.JoinCode = code
+	course.JoinCodeExpiresAt = expiresAt
+	course.JoinCodeMaxUses = maxUses
+	course.JoinCodeUses = 0
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.join_code.regenerate",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    joinCodeLimitDetails(expiresAt, maxUses),
+	})
+	log.Println("regenerate join code success: join code replaced")
+	c.JSON(200, joinCodeResponse(course))
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxJoinRequestBatch = 200
+
+// requestJoin is JoinCourse for courses that require approval: it records a
+// pending JoinRequest instead of enrolling the student. sectionID is set when
+// the student used a section's join code.
+func requestJoin(c *gin.Context, course models.Course, sectionID *uint, userID uint) {
+	var count int64
+	err := database.DB.Model(&models.JoinRequest{}).
+		Where("course_id = ? AND user_id = ? AND status = ?", course.ID, userID, models.JoinRequestPending).
+		Count(&count).Error
+	if err != nil {
+		log.Println("join course error: failed to check existing request")
+		c.JSON(500, gin.H{"message": "Failed to join course"})
+		return
+	}
+	if count > 0 {
+		log.Println("join course error: request already pending")
+		c.JSON(400, gin.H{"message": "Join request already pending"})
+		return
+	}
+	request := models.JoinRequest{
+		CourseID:  course.ID,
+		UserID:    userID,
+		SectionID: sectionID,
+		Status:    models.JoinRequestPending,
+		CreatedAt: time.Now(),
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := useJoinCode(tx, course.ID, sectionID); err != nil {
+			return err
+		}
+		return tx.Create(&request).Error
+	})
+	if err != nil {
+		if errors.Is(err, errJoinCodeUsedUp) {
+			log.Println("join course error: join code used up")
+			c.JSON(410, gin.H{"message": "Join code is no longer valid"})
+			return
+		}
+		if isUniqueViolation(err) {
+			log.Println("join course error: request already pending")
+			c.JSON(400, gin.H{"message": "Join request already pending"})
+			return
+		}
+		log.Println("join course error: failed to create join request", err)
+		c.JSON(500, gin.H{"message": "Failed to join course"})
+		return
+	}
+	log.Println("join course success: join request created")
+	c.JSON(202, gin.H{
+		"message":   "Join request sent, waiting for approval",
+		"courseId":  course.ID,
+		"requestId": request.ID,
+		"sectionId": request.SectionID,
+		"status":    request.Status,
+	})
+}
+
+func ListJoinRequests(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	requests := []models.JoinRequest{}
+	err := database.DB.Preload("User").
+		Where("course_id = ? AND status = ?", course.ID, models.JoinRequestPending).
+		Order("created_at").
+		Find(&requests).Error
+	if err != nil {
+		log.Println("list join requests error: failed to get requests")
+		c.JSON(500, gin.H{"message": "Failed to get join requests"})
+		return
+	}
+	result := make([]gin.H, 0, len(requests))
+	for _, request := range requests {
+		result = append(result, gin.H{
+			"id":          request.ID,
+			"userId":      request.UserID,
+			"name":        request.User.Name,
+			"email":       request.User.Email,
+			"requestedAt": request.CreatedAt,
+		})
+	}
+	log.Println("list join requests success: requests found")
+	c.JSON(200, gin.H{"requests": result})
+}
+
+type decideJoinRequestsRequest struct {
+	RequestIDs []uint `json:"requestIds"`
+	Reason     string `json:"reason"`
+}
+
+func bindJoinRequestDecision(c *gin.Context, action string) (decideJoinRequestsRequest, bool) {
+	var req decideJoinRequestsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.RequestIDs) == 0 {
+		log.Println(action + " error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return req, false
+	}
+	if len(req.RequestIDs) > maxJoinRequestBatch {
+		log.Println(action + " error: too many requests")
+		c.JSON(400, gin.H{"message": fmt.Sprintf("At most %d requests at a time", maxJoinRequestBatch)})
+		return req, false
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	return req, true
+}
+
+// pendingJoinRequests loads the pending requests of the course among ids.
+func pendingJoinRequests(courseID uint, ids []uint) ([]models.JoinRequest, error) {
+	requests := []models.JoinRequest{}
+	err := database.DB.Preload("User").
+		Where("course_id = ? AND status = ? AND id IN ?", courseID, models.JoinRequestPending, ids).
+		Order("created_at").
+		Find(&requests).Error
+	return requests, err
+}
+
+// decideJoinRequest moves a request out of pending. It reports false when
+// another request already decided it.
+func decideJoinRequest(tx *gorm.DB, request models.JoinRequest, status string, actorID uint, reason string) (bool, error) {
+	result := tx.Model(&models.JoinRequest{}).
+		Where("id = ? AND status = ?", request.ID, models.JoinRequestPending).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"decided_by": actorID,
+			"decided_at": time.Now(),
+			"reason":     reason,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// approvePendingJoinRequest approves the student's pending request to join
+// the course, if any, once staff added them another way.
+func approvePendingJoinRequest(tx *gorm.DB, courseID uint, userID uint, actorID uint, reason string) error {
+	return tx.Model(&models.JoinRequest{}).
+		Where("course_id = ? AND user_id = ? AND status = ?", courseID, userID, models.JoinRequestPending).
+		Updates(map[string]interface{}{
+			"status":     models.JoinRequestApproved,
+			"decided_by": actorID,
+			"decided_at": time.Now(),
+			"reason":     reason,
+		}).Error
+}
+
+// ApproveJoinRequests enrolls the students of the given pending requests, or
+// waitlists them when the course is full. Requests that aren't pending
+// anymore, whose student is blocked or whose section is full are reported as
+// skipped.
+func ApproveJoinRequests(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	req, ok := bindJoinRequestDecision(c, "approve join requests")
+	if !ok {
+		return
+	}
+	requests, err := pendingJoinRequests(course.ID, req.RequestIDs)
+	if err != nil {
+		log.Println("approve join requests error: failed to get requests")
+		c.JSON(500, gin.H{"message": "Failed to get join requests"})
+		return
+	}
+	actorID := c.GetUint("userID")
+	approved := []uint{}
+	waitlisted := []uint{}
+	for _, request := range requests {
+		block, err := courseBlock(course.ID, request.UserID)
+		if err != nil {
+			log.Println("approve join requests error: failed to check block")
+			c.JSON(500, gin.H{"message": "Failed to approve join requests"})
+			return
+		}
+		if block != nil {
+			continue
+		}
+		decided := false
+		status := joinEnrolled
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			ok, err := decideJoinRequest(tx, request, models.JoinRequestApproved, actorID, req.Reason)
+			if err != nil || !ok {
+				return err
+			}
+			decided = true
+			status, _, err = enrollOrWaitlist(tx, course.ID, request.SectionID, request.UserID)
+			if errors.Is(err, errAlreadyEnrolled) || errors.Is(err, errAlreadyWaitlisted) {
+				return nil
+			}
+			return err
+		})
+		if errors.Is(err, errSectionFull) {
+			// The request stays pending until the section has room.
+			continue
+		}
+		if err != nil {
+			log.Println("approve join requests error: failed to approve request", err)
+			c.JSON(500, gin.H{"message": "Failed to approve join requests"})
+			return
+		}
+		if !decided {
+			continue
+		}
+		approved = append(approved, request.ID)
+		body := fmt.Sprintf("Hi %s,\n\nYour request to join %s (%s) was approved.\n", request.User.Name, course.Name, course.Code)
+		if status == joinWaitlisted {
+			waitlisted = append(waitlisted, request.ID)
+			body += "\nThe course is full, so you are on its waitlist and will be enrolled when a seat opens up.\n"
+		}
+		err = mailer.Send(mailer.Message{
+			To:      request.User.Email,
+			Subject: fmt.Sprintf("Your request to join %s", course.Name),
+			Body:    body,
+		})
+		if err != nil {
+			log.Println("approve join requests error: failed to send email", err)
+		}
+	}
+	if len(approved) > 0 {
+		audit.Record(models.AuditEvent{
+			ActorID:    actorID,
+			Action:     "course.join_request.approve",
+			TargetType: "course",
+			TargetID:   course.ID,
+			IP:         c.ClientIP(),
+			Details:    fmt.Sprintf("requests=%v", approved),
+		})
+	}
+	log.Println("approve join requests success: requests approved")
+	c.JSON(200, gin.H{
+		"approved":   approved,
+		"waitlisted": waitlisted,
+		"skipped":    skippedIDs(req.RequestIDs, approved),
+	})
+}
+
+func DenyJoinRequests(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	req, ok := bindJoinRequestDecision(c, "deny join requests")
+	if !ok {
+		return
+	}
+	requests, err := pendingJoinRequests(course.ID, req.RequestIDs)
+	if err != nil {
+		log.Println("deny join requests error: failed to get requests")
+		c.JSON(500, gin.H{"message": "Failed to get join requests"})
+		return
+	}
+	actorID := c.GetUint("userID")
+	denied := []uint{}
+	for _, request := range requests {
+		decided, err := decideJoinRequest(database.DB, request, models.JoinRequestDenied, actorID, req.Reason)
+		if err != nil {
+			log.Println("deny join requests error: failed to deny request", err)
+			c.JSON(500, gin.H{"message": "Failed to deny join requests"})
+			return
+		}
+		if !decided {
+			continue
+		}
+		denied = append(denied, request.ID)
+		body := fmt.Sprintf("Hi %s,\n\nYour request to join %s (%s) was denied.\n", request.User.Name, course.Name, course.Code)
+		if req.Reason != "" {
+			body += fmt.Sprintf("\nReason: %s\n", req.Reason)
+		}
+		err = mailer.Send(mailer.Message{
+			To:      request.User.Email,
+			Subject: fmt.Sprintf("Your request to join %s", course.Name),
+			Body:    body,
+		})
+		if err != nil {
+			log.Println("deny join requests error: failed to send email", err)
+		}
+	}
+	if len(denied) > 0 {
+		audit.Record(models.AuditEvent{
+			ActorID:    actorID,
+			Action:     "course.join_request.deny",
+			TargetType: "course",
+			TargetID:   course.ID,
+			IP:         c.ClientIP(),
+			Details:    fmt.Sprintf("requests=%v reason=%q", denied, req.Reason),
+		})
+	}
+	log.Println("deny join requests success: requests denied")
+	c.JSON(200, gin.H{
+		"denied":  denied,
+		"skipped": skippedIDs(req.RequestIDs, denied),
+	})
+}
+
+func skippedIDs(requested []uint, done []uint) []uint {
+	doneSet := map[uint]bool{}
+	for _, id := range done {
+		doneSet[id] = true
+	}
+	skipped := []uint{}
+	for _, id := range requested {
+		if !doneSet[id] {
+			skipped = append(skipped, id)
+			doneSet[id] = true
+		}
+	}
+	return skipped
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"conductor_backend/internal/ratelimit"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code.
+// Both are marked used with conditional updates so concurrent requests
+// can't redeem the same code twice.
+func checkSecondFactor(user models.User, code string, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		result := database.DB.Model(&models.MFARecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, auth.HashRecoveryCode(recoveryCode)).
+			Update("used_at", time.Now())
+		return result.RowsAffected == 1, result.Error
+	}
+	step, ok := auth.VerifyTOTP(user.MFASecret, code, user.MFALastStep)
+	if !ok {
+		return false, nil
+	}
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND mfa_last_step < ?", user.ID, step).
+		Update("mfa_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a
+// fresh set. Only hashes are stored.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.MFARecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, models.MFARecoveryCode{
+			UserID:    userID,
+			CodeHash:  auth.HashRecoveryCode(code),
+			CreatedAt: time.Now(),
+		})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func SetupMFA(c *gin.Context) {
+	user := models.User{}
+	if err := database.DB.Where("id = ?", c.GetUint("userID")).First(&user).Error; err != nil {
+		log.Println("setup mfa error: user not found")
+		c.JSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if user.MFAEnabled() {
+		log.Println("setup mfa error: already enabled")
+		c.JSON(400, gin.H{"message": "Two-factor authentication is already enabled"})
+		return
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		log.Println("setup mfa error: failed to create secret")
+		c.JSON(500, gin.H{"message": "Failed to set up two-factor authentication"})
+		return
+	}
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{"mfa_secret": secret, "mfa_last_step": 0}).Error; err != nil {
+		log.Println("setup mfa error: failed to save user", err)
+		c.JSON(500, gin.H{"message": "Failed to save user"})
+		return
+	}
+	log.Println("setup mfa success: secret created")
+	c.JSON(200, gin.H{
+		"secret":          secret,
+		"provisioningUri": auth.TOTPProvisioningURI(secret, user.Email),
+	})
+}
+
+type mfaCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+func EnableMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		log.Println("enable mfa error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ?", c.GetUint("userID")).First(&user).Error; err != nil {
+		log.Println("enable mfa error: user not found")
+		c.JSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if user.MFAEnabled() {
+		log.Println("enable mfa error: already enabled")
+		c.JSON(400, gin.H{"message": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.MFASecret == "" {
+		log.Println("enable mfa error: setup not started")
+		c.JSON(400, gin.H{"message": "Start two-factor setup first"})
+		return
+	}
+	ok, err := checkSecondFactor(user, req.Code, "")
+	if err != nil {
+		log.Println("enable mfa error: failed to check code", err)
+		c.JSON(500, gin.H{"message": "Failed to enable two-factor authentication"})
+		return
+	}
+	if !ok {
+		log.Println("enable mfa error: invalid code")
+		c.JSON(400, gin.H{"message": "Invalid code"})
+		return
+	}
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("mfa_enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		log.Println("enable mfa error: failed to save user", err)
+		c.JSON(500, gin.H{"message": "Failed to enable two-factor authentication"})
+		return
+	}
+	database.RDB.Del(database.Ctx, fmt.Sprintf("user:%d", user.ID))
+	auth.InvalidatePermissions(user.ID)
+	log.Println("enable mfa success: two-factor enabled")
+	c.JSON(200, gin.H{
+		"message":       "Two-factor authentication enabled",
+		"recoveryCodes": codes,
+	})
+}
+
+func DisableMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		log.Println("disable mfa error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ?", c.GetUint("userID")).First(&user).Error; err != nil {
+		log.Println("disable mfa error: user not found")
+		c.JSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if !user.MFAEnabled() {
+		log.Println("disable mfa error: not enabled")
+		c.JSON(400, gin.H{"message": "Two-factor authentication is not enabled"})
+		return
+	}
+	if auth.MFARequired(user.Role) {
+		log.Println("disable mfa error: mandatory for role")
+		c.JSON(403, gin.H{"message": "Two-factor authentication is required for your account"})
+		return
+	}
+	ok, err := checkSecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Println("disable mfa error: failed to check code", err)
+		c.JSON(500, gin.H{"message": "Failed to disable two-factor authentication"})
+		return
+	}
+	if !ok {
+		log.Println("disable mfa error: invalid code")
+		c.JSON(400, gin.H{"message": "Invalid code"})
+		return
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_secret":     "",
+			"mfa_enabled_at": nil,
+			"mfa_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.MFARecoveryCode{}).Error
+	})
+	if err != nil {
+		log.Println("disable mfa error: failed to save user", err)
+		c.JSON(500, gin.H{"message": "Failed to disable two-factor authentication"})
+		return
+	}
+	database.RDB.Del(database.Ctx, fmt.Sprintf("user:%d", user.ID))
+	auth.InvalidatePermissions(user.ID)
+	log.Println("disable mfa success: two-factor disabled")
+	c.JSON(200, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		log.Println("regenerate recovery codes error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ?", c.GetUint("userID")).First(&user).Error; err != nil {
+		log.Println("regenerate recovery codes error: user not found")
+		c.JSON(401, gin.H{"message": "Unauthorized"})
+		return
+	}
+	if !user.MFAEnabled() {
+		log.Println("regenerate recovery codes error: not enabled")
+		c.JSON(400, gin.H{"message": "Two-factor authentication is not enabled"})
+		return
+	}
+	ok, err := checkSecondFactor(user, req.Code, "")
+	if err != nil || !ok {
+		log.Println("regenerate recovery codes error: invalid code")
+		c.JSON(400, gin.H{"message": "Invalid code"})
+		return
+	}
+	codes, err := replaceRecoveryCodes(database.DB, user.ID)
+	if err != nil {
+		log.Println("regenerate recovery codes error: failed to save codes", err)
+		c.JSON(500, gin.H{"message": "Failed to create recovery codes"})
+		return
+	}
+	log.Println("regenerate recovery codes success: codes replaced")
+	c.JSON(200, gin.H{"recoveryCodes": codes})
+}
+
+type loginMFARequest struct {
+	MFAToken     string `json:"mfaToken"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+	Device       string `json:"device"`
+}
+
+func LoginMFA(c *gin.Context) {
+	var req loginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		log.Println("login mfa error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	userID, err := auth.PeekMFAChallenge(req.MFAToken)
+	if err != nil {
+		log.Println("login mfa error: invalid or expired challenge")
+		c.JSON(401, gin.H{"message": "Invalid or expired MFA token"})
+		return
+	}
+	allowed, err := ratelimit.Allow(fmt.Sprintf("mfa_login:%d", userID), 5, auth.MFAChallengeTTL)
+	if err != nil {
+		log.Println("login mfa error: failed to check rate limit", err)
+		c.JSON(500, gin.H{"message": "Failed to log in"})
+		return
+	}
+	if !allowed {
+		auth.ConsumeMFAChallenge(req.MFAToken)
+		log.Println("login mfa error: too many attempts")
+		c.JSON(429, gin.H{"message": "Too many attempts, log in again"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil || !user.MFAEnabled() {
+		log.Println("login mfa error: user not found")
+		c.JSON(401, gin.H{"message": "Invalid or expired MFA token"})
+		return
+	}
+	ok, err := checkSecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Println("login mfa error: failed to check code", err)
+		c.JSON(500, gin.H{"message": "Failed to log in"})
+		return
+	}
+	if !ok {
+		log.Println("login mfa error: invalid code")
+		c.JSON(401, gin.H{"message": "Invalid code"})
+		return
+	}
+	if _, err := auth.ConsumeMFAChallenge(req.MFAToken); err != nil {
+		log.Println("login mfa error: challenge already used")
+		c.JSON(401, gin.H{"message": "Invalid or expired MFA token"})
+		return
+	}
+	respondTokens(c, user, req.Device)
+}
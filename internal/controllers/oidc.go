@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"conductor_backend/internal/oidc"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// oidcStateCookie binds a login attempt to the browser that started it, so
+// a callback URL from someone else's attempt can't sign this browser in.
+const oidcStateCookie = "oidc_state"
+
+var (
+	errSSOEmailUnverified   = errors.New("provider did not verify the email")
+	errSSOAccountUnverified = errors.New("local account with this email is not verified")
+)
+
+type oidcState struct {
+	CodeVerifier string `json:"codeVerifier"`
+	Nonce        string `json:"nonce"`
+}
+
+func oidcStateKey(state string) string {
+	return "oidc_state:" + state
+}
+
+// setOIDCStateCookie sets the state cookie, or clears it when maxAge is
+// negative. It is scoped to the callback and only sent over HTTPS when the
+// callback is.
+func setOIDCStateCookie(c *gin.Context, provider *oidc.Provider, state string, maxAge int) {
+	path := "/"
+	secure := false
+	if u, err := url.Parse(provider.RedirectURL); err == nil {
+		if u.Path != "" {
+			path = u.Path
+		}
+		secure = u.Scheme == "https"
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, path, "", secure, true)
+}
+
+func OIDCLogin(c *gin.Context) {
+	provider, err := oidc.Default()
+	if err != nil {
+		log.Println("oidc login error: not configured")
+		c.JSON(404, gin.H{"message": "Single sign-on is not configured"})
+		return
+	}
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		log.Println("oidc login error: failed to create state")
+		c.JSON(500, gin.H{"message": "Failed to start single sign-on"})
+		return
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		log.Println("oidc login error: failed to create nonce")
+		c.JSON(500, gin.H{"message": "Failed to start single sign-on"})
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		log.Println("oidc login error: failed to create PKCE verifier")
+		c.JSON(500, gin.H{"message": "Failed to start single sign-on"})
+		return
+	}
+	bytes, _ := json.Marshal(oidcState{CodeVerifier: verifier, Nonce: nonce})
+	if err := database.RDB.Set(database.Ctx, oidcStateKey(state), bytes, oidcStateTTL).Err(); err != nil {
+		log.Println("oidc login error: failed to save state", err)
+		c.JSON(500, gin.H{"message": "Failed to start single sign-on"})
+		return
+	}
+	authURL, err := provider.AuthURL(state, nonce, challenge)
+	if err != nil {
+		log.Println("oidc login error: failed to reach provider", err)
+		c.JSON(502, gin.H{"message": "Identity provider unavailable"})
+		return
+	}
+	setOIDCStateCookie(c, provider, state, int(oidcStateTTL.Seconds()))
+	log.Println("oidc login success: redirecting to provider")
+	c.Redirect(302, authURL)
+}
+
+// oidcFail sends the browser back to the frontend login page with an error
+// code, since the callback is a redirect and not an API call.
+func oidcFail(c *gin.Context, reason string) {
+	c.Redirect(302, appURL()+"/login?error="+url.QueryEscape(reason))
+}
+
+func OIDCCallback(c *gin.Context) {
+	provider, err := oidc.Default()
+	if err != nil {
+		log.Println("oidc callback error: not configured")
+		c.JSON(404, gin.H{"message": "Single sign-on is not configured"})
+		return
+	}
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, provider, "", -1)
+	if providerErr := c.Query("error"); providerErr != "" {
+		log.Println("oidc callback error: provider returned", providerErr)
+		oidcFail(c, "sso_denied")
+		return
+	}
+	if cookieState == "" || subtle.ConstantTimeCompare([]byte(cookieState), []byte(c.Query("state"))) != 1 {
+		log.Println("oidc callback error: state does not match this browser")
+		oidcFail(c, "sso_state_mismatch")
+		return
+	}
+	val, err := database.RDB.GetDel(database.Ctx, oidcStateKey(c.Query("state"))).Result()
+	if err != nil {
+		log.Println("oidc callback error: unknown or expired state")
+		oidcFail(c, "sso_expired")
+		return
+	}
+	var state oidcState
+	if err := json.Unmarshal([]byte(val), &state); err != nil {
+		log.Println("oidc callback error: corrupt state")
+		oidcFail(c, "sso_failed")
+		return
+	}
+	claims, err := provider.Exchange(c.Query("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Println("oidc callback error: failed to exchange code", err)
+		oidcFail(c, "sso_failed")
+		return
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		log.Println("oidc callback error: email missing or not verified")
+		oidcFail(c, "sso_email_unverified")
+		return
+	}
+	user, created, err := findOrCreateSSOUser(provider.Issuer, claims)
+	if errors.Is(err, errSSOEmailUnverified) {
+		log.Println("oidc callback error: email not verified")
+		oidcFail(c, "sso_email_unverified")
+		return
+	}
+	if errors.Is(err, errSSOAccountUnverified) {
+		log.Println("oidc callback error: local account not verified")
+		oidcFail(c, "sso_account_unverified")
+		return
+	}
+	if err != nil {
+		log.Println("oidc callback error: failed to link user", err)
+		oidcFail(c, "sso_failed")
+		return
+	}
+	// The provider verified the email, so invitations to it can be used.
+	if created {
+		acceptPendingInvitations(user)
+	}
+	code, err := auth.CreateLoginCode(user.ID)
+	if err != nil {
+		log.Println("oidc callback error: failed to create login code", err)
+		oidcFail(c, "sso_failed")
+		return
+	}
+	log.Println("oidc callback success: user signed in")
+	c.Redirect(302, appURL()+"/sso/callback?code="+url.QueryEscape(code))
+}
+
+// findOrCreateSSOUser returns the user linked to the provider subject,
+// linking an existing account with the same email or creating a new student
+// account when there is none. created reports whether the account is new.
+// Only verified local accounts are linked: anyone can register an unverified
+// account for an address they don't own and would otherwise share it with
+// the address's real owner.
+func findOrCreateSSOUser(issuer string, claims oidc.Claims) (models.User, bool, error) {
+	user := models.User{}
+	created := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		identity := models.UserIdentity{}
+		err := tx.Preload("User").Where("issuer = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+		if err == nil {
+			user = identity.User
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if !claims.EmailVerified {
+			return errSSOEmailUnverified
+		}
+
+		err = tx.Where("LOWER(email) = LOWER(?)", claims.Email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			name := claims.Name
+			if name == "" {
+				name = strings.Split(claims.Email, "@")[0]
+			}
+			now := time.Now()
+			user = models.User{
+				Email:          claims.Email,
+				Name:           name,
+				Role:           models.RoleStudent,
+				ApprovalStatus: models.ApprovalApproved,
+				CreatedAt:      now,
+				VerifiedAt:     &now,
+			}
+			err = tx.Create(&user).Error
+			created = err == nil
+		}
+		if err != nil {
+			return err
+		}
+		if !user.IsVerified() {
+			return errSSOAccountUnverified
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:    user.ID,
+			Issuer:    issuer,
+			Subject:   claims.Subject,
+			CreatedAt: time.Now(),
+		}).Error
+	})
+	if err == nil {
+		database.RDB.Del(database.Ctx, fmt.Sprintf("user:%d", user.ID))
+	}
+	return user, created, err
+}
+
+type oidcExchangeRequest struct {
+	Code   string `json:"code"`
+	Device string `json:"device"`
+}
+
+func OIDCExchange(c *gin.Context) {
+	var req oidcExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		log.Println("oidc exchange error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	userID, err := auth.ConsumeLoginCode(req.Code)
+	if err != nil {
+		log.Println("oidc exchange error: invalid or expired code")
+		c.JSON(401, gin.H{"message": "Invalid or expired code"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		log.Println("oidc exchange error: user not found")
+		c.JSON(401, gin.H{"message": "Invalid or expired code"})
+		return
+	}
+	log.Println("oidc exchange success: user found")
+	respondLogin(c, user, req.Device)
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"conductor_backend/internal/ratelimit"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+func ForgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		log.Println("forgot password error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	allowed, err := ratelimit.Allow("forgot_password:email:"+strings.ToLower(req.Email), 3, time.Hour)
+	if err == nil && allowed {
+		allowed, err = ratelimit.Allow("forgot_password:ip:"+c.ClientIP(), 10, time.Hour)
+	}
+	if err != nil {
+		log.Println("forgot password error: failed to check rate limit", err)
+		c.JSON(500, gin.H{"message": "Failed to send email"})
+		return
+	}
+	if !allowed {
+		log.Println("forgot password error: rate limited")
+		c.JSON(429, gin.H{"message": "Too many requests, try again later"})
+		return
+	}
+	// The lookup and the email happen in the background, so the response
+	// takes as long whether or not the email has an account.
+	go sendPasswordReset(req.Email)
+	log.Println("forgot password success: request accepted")
+	c.JSON(200, gin.H{"message": "If the email exists, a reset link has been sent"})
+}
+
+func sendPasswordReset(email string) {
+	user := models.User{}
+	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		log.Println("forgot password: no user for email")
+		return
+	}
+	token, err := auth.CreatePasswordResetToken(user.ID)
+	if err != nil {
+		log.Println("forgot password error: failed to create reset token", err)
+		return
+	}
+	err = mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Conductor password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to reset your password. It expires in %d minutes.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+			user.Name, int(auth.PasswordResetTTL.Minutes()), appLink("/reset-password", token),
+		),
+	})
+	if err != nil {
+		log.Println("forgot password error: failed to send email", err)
+		return
+	}
+	log.Println("forgot password success: reset email sent")
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Println("reset password error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	if req.Token == "" || req.Password == "" {
+		log.Println("reset password error: token or password is empty")
+		c.JSON(400, gin.H{"message": "Token and password are required"})
+		return
+	}
+	userID, err := auth.ConsumePasswordResetToken(req.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidOneTimeToken) {
+			log.Println("reset password error: invalid or expired token")
+			c.JSON(400, gin.H{"message": "Invalid or expired token"})
+			return
+		}
+		log.Println("reset password error: failed to check token", err)
+		c.JSON(500, gin.H{"message": "Failed to reset password"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		log.Println("reset password error: user not found")
+		c.JSON(400, gin.H{"message": "Invalid or expired token"})
+		return
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("reset password error: failed to hash password")
+		c.JSON(500, gin.H{"message": "Failed to hash password"})
+		return
+	}
+	if err := database.DB.Model(&user).Update("password_hash", string(hashed)).Error; err != nil {
+		log.Println("reset password error: failed to save user", err)
+		c.JSON(500, gin.H{"message": "Failed to save user"})
+		return
+	}
+	if err := auth.ClearLoginFailures(user.Email); err != nil {
+		log.Println("reset password error: failed to clear login failures", err)
+	}
+	database.RDB.Del(database.Ctx, fmt.Sprintf("user:%d", userID))
+	if err := auth.RevokeUserSessions(userID); err != nil {
+		log.Println("reset password error: failed to revoke sessions", err)
+	}
+	log.Println("reset password success: password updated")
+	c.JSON(200, gin.H{"message": "Password reset successfully"})
+}
+
+// appURL is the frontend base URL that emailed links and SSO redirects use.
+func appURL() string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:5173"
+	}
+	return base
+}
+
+// appLink builds a frontend URL carrying a token, e.g. for emailed links.
+func appLink(path string, token string) string {
+	return appURL() + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type removeStudentRequest struct {
+	Reason string `json:"reason"`
+	Block  bool   `json:"block"`
+}
+
+var (
+	errNotInCourse    = errors.New("student not in course")
+	errAlreadyRemoved = errors.New("enrollment already ended")
+)
+
+// leaveQueues takes the student off the course's waitlist and denies their
+// pending join request. It reports whether they had either.
+func leaveQueues(tx *gorm.DB, courseID uint, userID uint, actorID uint) (bool, error) {
+	result := tx.Where("course_id = ? AND user_id = ?", courseID, userID).Delete(&models.WaitlistEntry{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	left := result.RowsAffected > 0
+	result = tx.Model(&models.JoinRequest{}).
+		Where("course_id = ? AND user_id = ? AND status = ?", courseID, userID, models.JoinRequestPending).
+		Updates(map[string]interface{}{
+			"status":     models.JoinRequestDenied,
+			"decided_by": actorID,
+			"decided_at": time.Now(),
+			"reason":     "Removed by course staff",
+		})
+	return left || result.RowsAffected > 0, result.Error
+}
+
+// RemoveStudent removes an enrolled, waitlisted or pending student from the
+// course, optionally blocking them from rejoining.
+func RemoveStudent(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		log.Println("remove student error: invalid user ID")
+		c.JSON(400, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	var req removeStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Println("remove student error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		log.Println("remove student error: reason is required")
+		c.JSON(400, gin.H{"message": "Reason is required"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("remove student error: user not found")
+			c.JSON(404, gin.H{"message": "Student is not in this course"})
+			return
+		}
+		log.Println("remove student error: failed to get user")
+		c.JSON(500, gin.H{"message": "Failed to get user"})
+		return
+	}
+	enrollment := models.Enrollment{}
+	err = database.DB.Where("course_id = ? AND user_id = ? AND status = ?", course.ID, user.ID, models.EnrollmentActive).
+		First(&enrollment).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("remove student error: failed to get enrollment")
+		c.JSON(500, gin.H{"message": "Failed to get enrollment"})
+		return
+	}
+	removal := models.CourseRemoval{
+		CourseID:  course.ID,
+		UserID:    user.ID,
+		RemovedBy: c.GetUint("userID"),
+		Reason:    req.Reason,
+		Blocked:   req.Block,
+		CreatedAt: time.Now(),
+	}
+	var promoted []models.WaitlistEntry
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if enrollment.ID != 0 {
+			ended, err := endEnrollment(tx, enrollment.ID, models.EnrollmentRemoved)
+			if err != nil {
+				return err
+			}
+			if !ended {
+				return errAlreadyRemoved
+			}
+		}
+		left, err := leaveQueues(tx, course.ID, user.ID, removal.RemovedBy)
+		if err != nil {
+			return err
+		}
+		if enrollment.ID == 0 && !left {
+			return errNotInCourse
+		}
+		if err := tx.Create(&removal).Error; err != nil {
+			return err
+		}
+		if enrollment.ID != 0 {
+			promoted, err = promoteWaitlist(tx, course.ID)
+		}
+		return err
+	})
+	if errors.Is(err, errNotInCourse) {
+		log.Println("remove student error: student not in course")
+		c.JSON(404, gin.H{"message": "Student is not in this course"})
+		return
+	}
+	if errors.Is(err, errAlreadyRemoved) {
+		log.Println("remove student error: enrollment already ended")
+		c.JSON(409, gin.H{"message": "Student has already left this course"})
+		return
+	}
+	if err != nil {
+		log.Println("remove student error: failed to remove student", err)
+		c.JSON(500, gin.H{"message": "Failed to remove student"})
+		return
+	}
+	notifyPromoted(course, promoted)
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.student.remove",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("user=%d blocked=%t reason=%q", user.ID, req.Block, req.Reason),
+	})
+	err = mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("You were removed from %s", course.Name),
+		Body: fmt.Sprintf("Hi %s,\n\nYou were removed from %s (%s).\n\nReason: %s\n",
+			user.Name, course.Name, course.Code, req.Reason),
+	})
+	if err != nil {
+		log.Println("remove student error: failed to send email", err)
+	}
+	log.Println("remove student success: student removed")
+	c.JSON(200, gin.H{
+		"message": "Student removed successfully",
+		"blocked": req.Block,
+	})
+}
+
+func ListCourseBlocks(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	removals := []models.CourseRemoval{}
+	err := database.DB.Preload("User").
+		Where("course_id = ? AND blocked = ?", course.ID, true).
+		Order("created_at DESC").
+		Find(&removals).Error
+	if err != nil {
+		log.Println("list course blocks error: failed to get blocks")
+		c.JSON(500, gin.H{"message": "Failed to get blocked students"})
+		return
+	}
+	blocks := make([]gin.H, 0, len(removals))
+	for _, removal := range removals {
+		blocks = append(blocks, gin.H{
+			"userId":    removal.UserID,
+			"name":      removal.User.Name,
+			"email":     removal.User.Email,
+			"reason":    removal.Reason,
+			"blockedBy": removal.RemovedBy,
+			"blockedAt": removal.CreatedAt,
+		})
+	}
+	log.Println("list course blocks success: blocks found")
+	c.JSON(200, gin.H{"blocks": blocks})
+}
+
+func UnblockStudent(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		log.Println("unblock student error: invalid user ID")
+		c.JSON(400, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	result := database.DB.Model(&models.CourseRemoval{}).
+		Where("course_id = ? AND user_id = ? AND blocked = ?", course.ID, userID, true).
+		Updates(map[string]interface{}{"blocked": false, "unblocked_at": time.Now()})
+	if result.Error != nil {
+		log.Println("unblock student error: failed to unblock student")
+		c.JSON(500, gin.H{"message": "Failed to unblock student"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		log.Println("unblock student error: block not found")
+		c.JSON(404, gin.H{"message": "Student is not blocked from this course"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.student.unblock",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("user=%d", userID),
+	})
+	log.Println("unblock student success: student unblocked")
+	c.JSON(200, gin.H{"message": "Student unblocked successfully"})
+}
+
+// GetMyRemovals shows students the courses they were removed from and why.
+func GetMyRemovals(c *gin.Context) {
+	removals := []models.CourseRemoval{}
+	err := database.DB.Preload("Course", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Where("user_id = ?", c.GetUint("userID")).
+		Order("created_at DESC").
+		Find(&removals).Error
+	if err != nil {
+		log.Println("get my removals error: failed to get removals")
+		c.JSON(500, gin.H{"message": "Failed to get removals"})
+		return
+	}
+	result := make([]gin.H, 0, len(removals))
+	for _, removal := range removals {
+		result = append(result, gin.H{
+			"courseId":   removal.CourseID,
+			"courseName": removal.Course.Name,
+			"courseCode": removal.Course.Code,
+			"reason":     removal.Reason,
+			"blocked":    removal.Blocked,
+			"removedAt":  removal.CreatedAt,
+		})
+	}
+	log.Println("get my removals success: removals found")
+	c.JSON(200, gin.H{"removals": result})
+}
+
+// courseBlock returns the active block of userID in courseID, if any.
+func courseBlock(courseID uint, userID uint) (*models.CourseRemoval, error) {
+	removal := models.CourseRemoval{}
+	err := database.DB.Where("course_id = ? AND user_id = ? AND blocked = ?", courseID, userID, true).First(&removal).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &removal, nil
+}
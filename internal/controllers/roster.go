@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultRosterLimit = 50
+	maxRosterLimit     = 200
+)
+
+// rosterSortColumns maps the sort query parameter to the column it orders by.
+// "User" is the alias GORM gives the joined users table.
+var rosterSortColumns = map[string]string{
+	"name":   `"User"."name"`,
+	"email":  `"User"."email"`,
+	"joined": "enrollments.enrolled_at",
+}
+
+// rosterCursor marks the last row of a page: its sort value and enrollment ID
+// as a tie-breaker.
+type rosterCursor struct {
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeRosterCursor(cursor rosterCursor) string {
+	bytes, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func decodeRosterCursor(s string) (rosterCursor, bool) {
+	bytes, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return rosterCursor{}, false
+	}
+	var cursor rosterCursor
+	if err := json.Unmarshal(bytes, &cursor); err != nil || cursor.ID == 0 {
+		return rosterCursor{}, false
+	}
+	return cursor, true
+}
+
+func rosterSortValue(enrollment models.Enrollment, sort string) string {
+	switch sort {
+	case "email":
+		return enrollment.User.Email
+	case "joined":
+		return enrollment.EnrolledAt.Format(time.RFC3339Nano)
+	}
+	return enrollment.User.Name
+}
+
+// rosterStatuses are the values of the status query parameter.
+var rosterStatuses = map[string]bool{
+	models.EnrollmentActive:    true,
+	models.EnrollmentDropped:   true,
+	models.EnrollmentRemoved:   true,
+	models.EnrollmentCompleted: true,
+	"all":                      true,
+}
+
+// filterRoster applies the status, sectionId and q query parameters shared
+// by the roster and its export. The query must join users as "User".
+func filterRoster(c *gin.Context, query *gorm.DB, action string) (*gorm.DB, bool) {
+	status := c.DefaultQuery("status", models.EnrollmentActive)
+	if !rosterStatuses[status] {
+		log.Println(action + " error: invalid status")
+		c.JSON(400, gin.H{"message": "status must be active, dropped, removed, completed or all"})
+		return nil, false
+	}
+	if status != "all" {
+		query = query.Where("enrollments.status = ?", status)
+	}
+	if raw := c.Query("sectionId"); raw != "" {
+		sectionID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			log.Println(action + " error: invalid section ID")
+			c.JSON(400, gin.H{"message": "Invalid sectionId"})
+			return nil, false
+		}
+		if sectionID == 0 {
+			query = query.Where("enrollments.section_id IS NULL")
+		} else {
+			query = query.Where("enrollments.section_id = ?", sectionID)
+		}
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q) + "%"
+		query = query.Where(`("User"."name" ILIKE ? OR "User"."email" ILIKE ?)`, pattern, pattern)
+	}
+	return query, true
+}
+
+// GetCourseRoster lists enrolled students. Query parameters: q searches name
+// and email, status picks active (default), dropped, removed, completed or
+// all enrollments, sectionId limits it to one section (0 for students without
+// one), sort is name, email or joined, order is asc or desc, and limit/cursor
+// page through the results.
+func GetCourseRoster(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+
+	sort := c.DefaultQuery("sort", "name")
+	column, ok := rosterSortColumns[sort]
+	if !ok {
+		log.Println("get course roster error: invalid sort")
+		c.JSON(400, gin.H{"message": "sort must be name, email or joined"})
+		return
+	}
+	order := strings.ToLower(c.DefaultQuery("order", "asc"))
+	if order != "asc" && order != "desc" {
+		log.Println("get course roster error: invalid order")
+		c.JSON(400, gin.H{"message": "order must be asc or desc"})
+		return
+	}
+	limit := defaultRosterLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			log.Println("get course roster error: invalid limit")
+			c.JSON(400, gin.H{"message": "Invalid limit"})
+			return
+		}
+		limit = min(n, maxRosterLimit)
+	}
+
+	query, ok := filterRoster(c, database.DB.Joins("User").Where("enrollments.course_id = ?", course.ID), "get course roster")
+	if !ok {
+		return
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, ok := decodeRosterCursor(raw)
+		if !ok {
+			log.Println("get course roster error: invalid cursor")
+			c.JSON(400, gin.H{"message": "Invalid cursor"})
+			return
+		}
+		var value interface{} = cursor.Value
+		if sort == "joined" {
+			t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				log.Println("get course roster error: invalid cursor")
+				c.JSON(400, gin.H{"message": "Invalid cursor"})
+				return
+			}
+			value = t
+		}
+		comparison := ">"
+		if order == "desc" {
+			comparison = "<"
+		}
+		query = query.Where("("+column+", enrollments.id) "+comparison+" (?, ?)", value, cursor.ID)
+	}
+
+	enrollments := []models.Enrollment{}
+	err := query.
+		Preload("Section").
+		Order(column + " " + order).
+		Order("enrollments.id " + order).
+		Limit(limit + 1).
+		Find(&enrollments).Error
+	if err != nil {
+		log.Println("get course roster error: failed to get enrollments", err)
+		c.JSON(500, gin.H{"message": "Failed to get roster"})
+		return
+	}
+
+	var nextCursor *string
+	if len(enrollments) > limit {
+		enrollments = enrollments[:limit]
+		last := enrollments[len(enrollments)-1]
+		cursor := encodeRosterCursor(rosterCursor{Value: rosterSortValue(last, sort), ID: last.ID})
+		nextCursor = &cursor
+	}
+	students := make([]gin.H, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		students = append(students, gin.H{
+			"id":           enrollment.User.ID,
+			"name":         enrollment.User.Name,
+			"email":        enrollment.User.Email,
+			"joinedAt":     enrollment.EnrolledAt,
+			"status":       enrollment.Status,
+			"section":      sectionSummary(enrollment.Section),
+			"endedAt":      enrollment.EndedAt,
+			"enrollmentId": enrollment.ID,
+		})
+	}
+	log.Println("get course roster success: roster found")
+	c.JSON(200, gin.H{
+		"students":   students,
+		"nextCursor": nextCursor,
+	})
+}
@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxRosterImportBytes = 1 << 20
+	maxRosterImportRows  = 2000
+	rosterImportBatch    = 100
+)
+
+// Row statuses of a roster import.
+const (
+	importEnrolled          = "enrolled"
+	importWaitlisted        = "waitlisted"
+	importInvited           = "invited"
+	importAlreadyEnrolled   = "already_enrolled"
+	importAlreadyWaitlisted = "already_waitlisted"
+	importAlreadyInvited    = "already_invited"
+	importBlocked           = "blocked"
+	importStaff             = "staff"
+	importIneligible        = "ineligible"
+	importSectionFull       = "section_full"
+	importInvalid           = "invalid"
+	importDuplicate         = "duplicate"
+	importNotImported       = "not_imported"
+)
+
+var errDryRun = errors.New("dry run")
+
+type rosterImportRow struct {
+	Row     int    `json:"row"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Section string `json:"section,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+
+	sectionID  *uint
+	user       *models.User
+	invitation *models.Invitation
+}
+
+// parseRosterCSV reads the rows of a roster CSV. The header row needs an
+// email column; name and section columns are optional. Rows with an invalid
+// email or an email that was already listed are marked right away.
+func parseRosterCSV(r io.Reader) ([]rosterImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("CSV must have an email column")
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []rosterImportRow{}
+	seen := map[string]int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxRosterImportRows {
+			return nil, fmt.Errorf("CSV has more than %d rows", maxRosterImportRows)
+		}
+		line, _ := reader.FieldPos(0)
+		row := rosterImportRow{
+			Row:     line,
+			Email:   field(record, "email"),
+			Name:    field(record, "name"),
+			Section: field(record, "section"),
+		}
+		key := strings.ToLower(row.Email)
+		if !validEmail(row.Email) {
+			row.Status = importInvalid
+			row.Message = "Invalid email address"
+		} else if first, ok := seen[key]; ok {
+			row.Status = importDuplicate
+			row.Message = fmt.Sprintf("Email already listed on row %d", first)
+		} else {
+			seen[key] = row.Row
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// importByInvitation reports whether a listed email is sent an invitation
+// instead of being enrolled: it has no account yet, or a student account
+// whose email isn't verified. Those students join once they verify it, like
+// students who join themselves.
+func importByInvitation(user *models.User) bool {
+	return user == nil || (user.Role == models.RoleStudent && !user.IsVerified())
+}
+
+// readRosterImport returns the uploaded CSV: the file form field of a
+// multipart request, or else the request body.
+func readRosterImport(c *gin.Context) (io.Reader, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRosterImportBytes)
+	if c.ContentType() != "multipart/form-data" {
+		return c.Request.Body, nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	return header.Open()
+}
+
+// ImportRoster adds the students listed in a CSV to the course. Verified
+// students are enrolled, or waitlisted when the course is full, and emailed.
+// Other emails are sent an invitation, except those of professors and
+// admins, which are skipped. Every row is reported
+// with its status; with dryRun=true nothing is saved and nobody is emailed.
+func ImportRoster(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+	if err != nil {
+		log.Println("import roster error: invalid dryRun")
+		c.JSON(400, gin.H{"message": "dryRun must be true or false"})
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	file, err := readRosterImport(c)
+	if err != nil {
+		if errors.As(err, &tooLarge) {
+			log.Println("import roster error: file too large")
+			c.JSON(413, gin.H{"message": "CSV must be at most 1 MB"})
+			return
+		}
+		log.Println("import roster error: missing file")
+		c.JSON(400, gin.H{"message": "Upload the CSV as the file field or the request body"})
+		return
+	}
+	rows, err := parseRosterCSV(file)
+	if err != nil {
+		if errors.As(err, &tooLarge) {
+			log.Println("import roster error: file too large")
+			c.JSON(413, gin.H{"message": "CSV must be at most 1 MB"})
+			return
+		}
+		log.Println("import roster error: invalid CSV", err)
+		c.JSON(400, gin.H{"message": err.Error()})
+		return
+	}
+
+	sections := []models.Section{}
+	if err := database.DB.Where("course_id = ?", course.ID).Find(&sections).Error; err != nil {
+		log.Println("import roster error: failed to get sections")
+		c.JSON(500, gin.H{"message": "Failed to import roster"})
+		return
+	}
+	sectionsByName := map[string]uint{}
+	for _, section := range sections {
+		sectionsByName[strings.ToLower(section.Name)] = section.ID
+	}
+	emails := []string{}
+	for i := range rows {
+		row := &rows[i]
+		if row.Status != "" {
+			continue
+		}
+		if row.Section != "" {
+			sectionID, ok := sectionsByName[strings.ToLower(row.Section)]
+			if !ok {
+				row.Status = importInvalid
+				row.Message = "Unknown section"
+				continue
+			}
+			row.sectionID = &sectionID
+		}
+		emails = append(emails, strings.ToLower(row.Email))
+	}
+	users := []models.User{}
+	if len(emails) > 0 {
+		if err := database.DB.Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+			log.Println("import roster error: failed to get users")
+			c.JSON(500, gin.H{"message": "Failed to import roster"})
+			return
+		}
+	}
+	usersByEmail := map[string]*models.User{}
+	for i := range users {
+		usersByEmail[strings.ToLower(users[i].Email)] = &users[i]
+	}
+	staff := map[uint]bool{course.ProfessorID: true}
+	staffIDs := []uint{}
+	blockedIDs := []uint{}
+	if err := database.DB.Model(&models.CourseRoleAssignment{}).Where("course_id = ?", course.ID).Pluck("user_id", &staffIDs).Error; err != nil {
+		log.Println("import roster error: failed to get staff")
+		c.JSON(500, gin.H{"message": "Failed to import roster"})
+		return
+	}
+	for _, id := range staffIDs {
+		staff[id] = true
+	}
+	err = database.DB.Model(&models.CourseRemoval{}).
+		Where("course_id = ? AND blocked = ?", course.ID, true).
+		Pluck("user_id", &blockedIDs).Error
+	if err != nil {
+		log.Println("import roster error: failed to get blocks")
+		c.JSON(500, gin.H{"message": "Failed to import roster"})
+		return
+	}
+	blocked := map[uint]bool{}
+	for _, id := range blockedIDs {
+		blocked[id] = true
+	}
+
+	actorID := c.GetUint("userID")
+	importRow := func(tx *gorm.DB, row *rosterImportRow) error {
+		email := strings.ToLower(row.Email)
+		user := usersByEmail[email]
+		if importByInvitation(user) {
+			if err := revokeExpiredInvitation(tx, course.ID, email); err != nil {
+				return err
+			}
+			var pending int64
+			err := tx.Model(&models.Invitation{}).
+				Where("course_id = ? AND email = ? AND status = ?", course.ID, email, models.InvitationPending).
+				Count(&pending).Error
+			if err != nil {
+				return err
+			}
+			if pending > 0 {
+				row.Status = importAlreadyInvited
+				return nil
+			}
+			invitation := models.Invitation{
+				CourseID:  course.ID,
+				Email:     email,
+				Name:      row.Name,
+				SectionID: row.sectionID,
+				Status:    models.InvitationPending,
+				InvitedBy: actorID,
+				CreatedAt: time.Now(),
+				ExpiresAt: time.Now().Add(models.InvitationTTL),
+			}
+			if err := tx.Create(&invitation).Error; err != nil {
+				return err
+			}
+			row.Status = importInvited
+			row.invitation = &invitation
+			return nil
+		}
+		row.user = user
+		if staff[user.ID] {
+			row.Status = importStaff
+			row.Message = "Course staff can't be enrolled"
+			return nil
+		}
+		if user.Role != models.RoleStudent {
+			row.Status = importIneligible
+			row.Message = "Only student accounts can be enrolled"
+			return nil
+		}
+		if blocked[user.ID] {
+			row.Status = importBlocked
+			row.Message = "Student is blocked from this course"
+			return nil
+		}
+		status, _, err := enrollOrWaitlist(tx, course.ID, row.sectionID, user.ID)
+		switch {
+		case errors.Is(err, errAlreadyEnrolled):
+			row.Status = importAlreadyEnrolled
+			return nil
+		case errors.Is(err, errAlreadyWaitlisted):
+			row.Status = importAlreadyWaitlisted
+			return nil
+		case errors.Is(err, errSectionFull):
+			row.Status = importSectionFull
+			row.Message = "Section is full"
+			return nil
+		case err != nil:
+			return err
+		}
+		row.Status = importEnrolled
+		if status == joinWaitlisted {
+			row.Status = importWaitlisted
+		}
+		return approvePendingJoinRequest(tx, course.ID, user.ID, actorID, "Added by roster import")
+	}
+	// importRows runs rows[from:to] in one transaction. On failure the rows
+	// get their previous status back, since nothing of theirs was saved.
+	importRows := func(tx *gorm.DB, from int, to int) error {
+		done := []*rosterImportRow{}
+		for i := from; i < to; i++ {
+			row := &rows[i]
+			if row.Status != "" {
+				continue
+			}
+			done = append(done, row)
+			if err := importRow(tx, row); err != nil {
+				for _, row := range done {
+					row.Status, row.Message = "", ""
+					row.user, row.invitation = nil, nil
+				}
+				return err
+			}
+		}
+		return nil
+	}
+
+	var importErr error
+	if dryRun {
+		// A dry run has to see its own seats being taken, so it runs as one
+		// transaction that is rolled back.
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := importRows(tx, 0, len(rows)); err != nil {
+				return err
+			}
+			return errDryRun
+		})
+		if !errors.Is(err, errDryRun) {
+			importErr = err
+		}
+	} else {
+		// Every batch commits on its own, so the course stays locked for a
+		// batch at a time and other joins can get in between.
+		for from := 0; from < len(rows) && importErr == nil; from += rosterImportBatch {
+			to := min(from+rosterImportBatch, len(rows))
+			importErr = database.DB.Transaction(func(tx *gorm.DB) error {
+				return importRows(tx, from, to)
+			})
+		}
+	}
+	if importErr != nil && dryRun {
+		log.Println("import roster error: failed to import roster", importErr)
+		c.JSON(500, gin.H{"message": "Failed to import roster"})
+		return
+	}
+	for i := range rows {
+		if rows[i].Status == "" {
+			rows[i].Status = importNotImported
+			rows[i].Message = "Import stopped before this row"
+		}
+	}
+
+	summary := map[string]int{}
+	for _, row := range rows {
+		summary[row.Status]++
+	}
+	if !dryRun {
+		for _, row := range rows {
+			if row.Status == importInvited {
+				if err := sendInvitationEmail(course, *row.invitation); err != nil {
+					log.Println("import roster error: failed to send invitation", err)
+				}
+				continue
+			}
+			if row.Status != importEnrolled && row.Status != importWaitlisted {
+				continue
+			}
+			body := fmt.Sprintf("Hi %s,\n\nYou were added to %s (%s) by its staff.\n", row.user.Name, course.Name, course.Code)
+			if row.Status == importWaitlisted {
+				body += "\nThe course is full, so you are on its waitlist and will be enrolled when a seat opens up.\n"
+			}
+			err := mailer.Send(mailer.Message{
+				To:      row.user.Email,
+				Subject: fmt.Sprintf("You were added to %s", course.Name),
+				Body:    body,
+			})
+			if err != nil {
+				log.Println("import roster error: failed to send email", err)
+			}
+		}
+		audit.Record(models.AuditEvent{
+			ActorID:    actorID,
+			Action:     "course.roster.import",
+			TargetType: "course",
+			TargetID:   course.ID,
+			IP:         c.ClientIP(),
+			Details: fmt.Sprintf("rows=%d enrolled=%d waitlisted=%d invited=%d",
+				len(rows), summary[importEnrolled], summary[importWaitlisted], summary[importInvited]),
+		})
+	}
+	if importErr != nil {
+		// The batches before the failure were saved, so report them.
+		log.Println("import roster error: failed to import roster", importErr)
+		c.JSON(500, gin.H{
+			"message": "Failed to import roster",
+			"dryRun":  dryRun,
+			"summary": summary,
+			"rows":    rows,
+		})
+		return
+	}
+	log.Println("import roster success: roster imported")
+	c.JSON(200, gin.H{
+		"dryRun":  dryRun,
+		"summary": summary,
+		"rows":    rows,
+	})
+}
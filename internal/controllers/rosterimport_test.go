@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"conductor_backend/internal/models"
+)
+
+func TestImportByInvitation(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		user *models.User
+		want bool
+	}{
+		{name: "no account", want: true},
+		{name: "unverified student", user: &models.User{Role: models.RoleStudent}, want: true},
+		{name: "verified student", user: &models.User{Role: models.RoleStudent, VerifiedAt: &now}},
+		{name: "professor", user: &models.User{Role: models.RoleProfessor, VerifiedAt: &now}},
+		{name: "unverified professor", user: &models.User{Role: models.RoleProfessor}},
+		{name: "admin", user: &models.User{Role: models.RoleAdmin, VerifiedAt: &now}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := importByInvitation(tt.user); got != tt.want {
+				t.Errorf("importByInvitation = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRosterCSV(t *testing.T) {
+	csv := "\ufeffEmail,Name,Section\n" +
+		"ada@example.edu,Ada Lovelace,Lab A\n" +
+		"not-an-email,Nobody,\n" +
+		"ADA@example.edu,Ada again,\n" +
+		"alan@example.edu\n"
+	rows, err := parseRosterCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("parseRosterCSV: %v", err)
+	}
+	want := []rosterImportRow{
+		{Row: 2, Email: "ada@example.edu", Name: "Ada Lovelace", Section: "Lab A"},
+		{Row: 3, Email: "not-an-email", Name: "Nobody", Status: importInvalid, Message: "Invalid email address"},
+		{Row: 4, Email: "ADA@example.edu", Name: "Ada again", Status: importDuplicate, Message: "Email already listed on row 2"},
+		{Row: 5, Email: "alan@example.edu"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"", "name\nAda\n"} {
+		if _, err := parseRosterCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseRosterCSV(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,644 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"conductor_backend/internal/ratelimit"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findByJoinCode looks the code up among course join codes first and section
+// join codes second. section is nil for a course code.
+func findByJoinCode(code string) (models.Course, *models.Section, error) {
+	course := models.Course{}
+	err := database.DB.Where("join_code = ?", code).First(&course).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return course, nil, err
+	}
+	section := models.Section{}
+	if err := database.DB.Where("join_code = ?", code).First(&section).Error; err != nil {
+		return course, nil, err
+	}
+	if err := database.DB.First(&course, section.CourseID).Error; err != nil {
+		return course, nil, err
+	}
+	return course, &section, nil
+}
+
+// sectionSummary is how section membership appears in rosters and
+// enrollment listings.
+func sectionSummary(section *models.Section) gin.H {
+	if section == nil {
+		return nil
+	}
+	return gin.H{"id": section.ID, "name": section.Name}
+}
+
+// loadSection loads the :sectionId section of the course, answering the
+// request itself when it can't.
+func loadSection(c *gin.Context, course models.Course, action string) (models.Section, bool) {
+	section := models.Section{}
+	sectionID, err := strconv.ParseUint(c.Param("sectionId"), 10, 64)
+	if err != nil {
+		log.Println(action + " error: invalid section ID")
+		c.JSON(400, gin.H{"message": "Invalid section ID"})
+		return section, false
+	}
+	if err := database.DB.Where("id = ? AND course_id = ?", sectionID, course.ID).First(&section).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println(action + " error: section not found")
+			c.JSON(404, gin.H{"message": "Section not found"})
+			return section, false
+		}
+		log.Println(action + " error: failed to get section")
+		c.JSON(500, gin.H{"message": "Failed to get section"})
+		return section, false
+	}
+	return section, true
+}
+
+func ListSections(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	sections := []models.Section{}
+	if err := database.DB.Where("course_id = ?", course.ID).Order("name").Find(&sections).Error; err != nil {
+		log.Println("list sections error: failed to get sections")
+		c.JSON(500, gin.H{"message": "Failed to get sections"})
+		return
+	}
+	sectionIDs := make([]uint, 0, len(sections))
+	for _, section := range sections {
+		sectionIDs = append(sectionIDs, section.ID)
+	}
+	var counts []struct {
+		SectionID uint
+		Count     int64
+	}
+	staff := []models.SectionStaff{}
+	if len(sectionIDs) > 0 {
+		err := database.DB.Model(&models.Enrollment{}).
+			Select("section_id, COUNT(*) AS count").
+			Where("section_id IN ? AND status = ?", sectionIDs, models.EnrollmentActive).
+			Group("section_id").
+			Scan(&counts).Error
+		if err != nil {
+			log.Println("list sections error: failed to count enrollments")
+			c.JSON(500, gin.H{"message": "Failed to get sections"})
+			return
+		}
+		if err := database.DB.Preload("User").Where("section_id IN ?", sectionIDs).Order("created_at").Find(&staff).Error; err != nil {
+			log.Println("list sections error: failed to get staff")
+			c.JSON(500, gin.H{"message": "Failed to get sections"})
+			return
+		}
+	}
+	enrolled := map[uint]int64{}
+	for _, count := range counts {
+		enrolled[count.SectionID] = count.Count
+	}
+	staffBySection := map[uint][]gin.H{}
+	for _, member := range staff {
+		staffBySection[member.SectionID] = append(staffBySection[member.SectionID], gin.H{
+			"userId": member.UserID,
+			"name":   member.User.Name,
+			"email":  member.User.Email,
+		})
+	}
+	result := make([]gin.H, 0, len(sections))
+	for _, section := range sections {
+		sectionStaff := staffBySection[section.ID]
+		if sectionStaff == nil {
+			sectionStaff = []gin.H{}
+		}
+		result = append(result, gin.H{
+			"id":       section.ID,
+			"name":     section.Name,
+			"capacity": section.Capacity,
+			"enrolled": enrolled[section.ID],
+			"staff":    sectionStaff,
+		})
+	}
+	log.Println("list sections success: sections found")
+	c.JSON(200, gin.H{"sections": result})
+}
+
+type sectionRequest struct {
+	Name     *string `json:"name"`
+	Capacity *int    `json:"capacity"`
+}
+
+// validateSectionRequest trims the name in place and returns an error
+// message per invalid field.
+func validateSectionRequest(req *sectionRequest) map[string]string {
+	fieldErrors := map[string]string{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		req.Name = &name
+		switch {
+		case name == "":
+			fieldErrors["name"] = "Name cannot be empty"
+		case len(name) > 100:
+			fieldErrors["name"] = "Name must be at most 100 characters"
+		}
+	}
+	if req.Capacity != nil && *req.Capacity < 0 {
+		fieldErrors["capacity"] = "Capacity cannot be negative"
+	}
+	return fieldErrors
+}
+
+func CreateSection(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	var req sectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil {
+		log.Println("create section error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	if fieldErrors := validateSectionRequest(&req); len(fieldErrors) > 0 {
+		log.Println("create section error: invalid fields")
+		c.JSON(400, gin.H{"message": "Invalid section", "errors": fieldErrors})
+		return
+	}
+	if req.Capacity != nil && *req.Capacity == 0 {
+		req.Capacity = nil
+	}
+	joinCode, err := models.NewJoinCode()
+	if err != nil {
+		log.Println("create section error: failed to create join code")
+		c.JSON(500, gin.H{"message": "Failed to create section"})
+		return
+	}
+	section := models.Section{
+		CourseID:  course.ID,
+		Name:      *req.Name,
+		JoinCode:  joinCode,
+		Capacity:  req.Capacity,
+		CreatedAt: time.Now(),
+	}
+	if err := database.DB.Create(&section).Error; err != nil {
+		if isUniqueViolation(err) {
+			log.Println("create section error: name already in use")
+			c.JSON(409, gin.H{"message": "Section name already in use", "errors": gin.H{"name": "Section name already in use"}})
+			return
+		}
+		log.Println("create section error: failed to create section", err)
+		c.JSON(500, gin.H{"message": "Failed to create section"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.section.create",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("section=%d", section.ID),
+	})
+	log.Println("create section success: section created")
+	c.JSON(201, gin.H{
+		"id":        section.ID,
+		"name":      section.Name,
+		"capacity":  section.Capacity,
+		"joinCode":  section.JoinCode,
+		"createdAt": section.CreatedAt,
+	})
+}
+
+func UpdateSection(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	section, ok := loadSection(c, course, "update section")
+	if !ok {
+		return
+	}
+	var req sectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Name == nil && req.Capacity == nil) {
+		log.Println("update section error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	if fieldErrors := validateSectionRequest(&req); len(fieldErrors) > 0 {
+		log.Println("update section error: invalid fields")
+		c.JSON(400, gin.H{"message": "Invalid section", "errors": fieldErrors})
+		return
+	}
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Capacity != nil {
+		// A capacity of 0 removes the limit.
+		if *req.Capacity == 0 {
+			updates["capacity"] = nil
+		} else {
+			updates["capacity"] = *req.Capacity
+		}
+	}
+	var promoted []models.WaitlistEntry
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&section).Updates(updates).Error; err != nil {
+			return err
+		}
+		if _, ok := updates["capacity"]; !ok {
+			return nil
+		}
+		var err error
+		promoted, err = promoteWaitlist(tx, course.ID)
+		return err
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Println("update section error: name already in use")
+			c.JSON(409, gin.H{"message": "Section name already in use", "errors": gin.H{"name": "Section name already in use"}})
+			return
+		}
+		log.Println("update section error: failed to update section", err)
+		c.JSON(500, gin.H{"message": "Failed to update section"})
+		return
+	}
+	notifyPromoted(course, promoted)
+	if err := database.DB.First(&section, section.ID).Error; err != nil {
+		log.Println("update section error: failed to reload section")
+		c.JSON(500, gin.H{"message": "Failed to get section"})
+		return
+	}
+	log.Println("update section success: section updated")
+	c.JSON(200, gin.H{
+		"id":        section.ID,
+		"name":      section.Name,
+		"capacity":  section.Capacity,
+		"updatedAt": section.UpdatedAt,
+	})
+}
+
+// DeleteSection removes a section. Its students stay enrolled in the course
+// without a section.
+func DeleteSection(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	section, ok := loadSection(c, course, "delete section")
+	if !ok {
+		return
+	}
+	var promoted []models.WaitlistEntry
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.Enrollment{}, &models.WaitlistEntry{}, &models.JoinRequest{}} {
+			if err := tx.Model(model).Where("section_id = ?", section.ID).Update("section_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("section_id = ?", section.ID).Delete(&models.SectionStaff{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&section).Error; err != nil {
+			return err
+		}
+		// Waitlisted students of a full section may fit now.
+		var err error
+		promoted, err = promoteWaitlist(tx, course.ID)
+		return err
+	})
+	if err != nil {
+		log.Println("delete section error: failed to delete section", err)
+		c.JSON(500, gin.H{"message": "Failed to delete section"})
+		return
+	}
+	notifyPromoted(course, promoted)
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.section.delete",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("section=%d name=%q", section.ID, section.Name),
+	})
+	log.Println("delete section success: section deleted")
+	c.JSON(200, gin.H{"message": "Section deleted successfully"})
+}
+
+func GetSectionJoinCode(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	section, ok := loadSection(c, course, "get section join code")
+	if !ok {
+		return
+	}
+	log.Println("get section join code success: join code found")
+	c.JSON(200, sectionJoinCodeResponse(section))
+}
+
+// RegenerateSectionJoinCode replaces the section's join code, which
+// immediately stops the old one from working, and resets its use count. It
+// takes the same expiry and use limit as RegenerateJoinCode.
+func RegenerateSectionJoinCode(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	section, ok := loadSection(c, course, "regenerate section join code")
+	if !ok {
+		return
+	}
+	expiresAt, maxUses, ok := bindJoinCodeLimits(c, "regenerate section join code")
+	if !ok {
+		return
+	}
+	code, err := models.NewJoinCode()
+	if err != nil {
+		log.Println("regenerate section join code error: failed to create code")
+		c.JSON(500, gin.H{"message": "Failed to create join code"})
+		return
+	}
+	err = database.DB.Model(&section).Updates(map[string]interface{}{
+		"join_code":            code,
+		"join_code_expires_at": expiresAt,
+		"join_code_max_uses":   maxUses,
+		"join_code_uses":       0,
+	}).Error
+	if err != nil {
+		log.Println("regenerate section join code error: failed to save code", err)
+		c.JSON(500, gin.H{"message": "Failed to create join code"})
+		return
+	}
+	section.JoinCode = code
+	section.JoinCodeExpiresAt = expiresAt
+	section.JoinCodeMaxUses = maxUses
+	section.JoinCodeUses = 0
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.section.join_code.regenerate",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("section=%d ", section.ID) + joinCodeLimitDetails(expiresAt, maxUses),
+	})
+	log.Println("regenerate section join code success: join code replaced")
+	c.JSON(200, sectionJoinCodeResponse(section))
+}
+
+type sectionStaffRequest struct {
+	UserID uint `json:"userId"`
+}
+
+// AddSectionStaff assigns an existing member of the course staff to the
+// section.
+func AddSectionStaff(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	section, ok := loadSection(c, course, "add section staff")
+	if !ok {
+		return
+	}
+	var req sectionStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserID == 0 {
+		log.Println("add section staff error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	if req.UserID != course.ProfessorID {
+		var count int64
+		err := database.DB.Model(&models.CourseRoleAssignment{}).
+			Where("course_id = ? AND user_id = ?", course.ID, req.UserID).
+			Count(&count).Error
+		if err != nil {
+			log.Println("add section staff error: failed to check staff")
+			c.JSON(500, gin.H{"message": "Failed to add section staff"})
+			return
+		}
+		if count == 0 {
+			log.Println("add section staff error: user is not course staff")
+			c.JSON(400, gin.H{"message": "User is not on the course staff"})
+			return
+		}
+	}
+	member := models.SectionStaff{
+		SectionID: section.ID,
+		UserID:    req.UserID,
+		CreatedAt: time.Now(),
+	}
+	if err := database.DB.Create(&member).Error; err != nil {
+		if isUniqueViolation(err) {
+			log.Println("add section staff error: already assigned")
+			c.JSON(409, gin.H{"message": "User is already assigned to this section"})
+			return
+		}
+		log.Println("add section staff error: failed to save assignment", err)
+		c.JSON(500, gin.H{"message": "Failed to add section staff"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.section.staff.add",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("section=%d user=%d", section.ID, req.UserID),
+	})
+	log.Println("add section staff success: staff assigned")
+	c.JSON(201, gin.H{"message": "Staff member assigned to section"})
+}
+
+func RemoveSectionStaff(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	section, ok := loadSection(c, course, "remove section staff")
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		log.Println("remove section staff error: invalid user ID")
+		c.JSON(400, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	result := database.DB.Where("section_id = ? AND user_id = ?", section.ID, userID).Delete(&models.SectionStaff{})
+	if result.Error != nil {
+		log.Println("remove section staff error: failed to delete assignment")
+		c.JSON(500, gin.H{"message": "Failed to remove section staff"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		log.Println("remove section staff error: staff not found")
+		c.JSON(404, gin.H{"message": "Staff member not assigned to this section"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.section.staff.remove",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("section=%d user=%d", section.ID, userID),
+	})
+	log.Println("remove section staff success: staff removed")
+	c.JSON(200, gin.H{"message": "Staff member removed from section"})
+}
+
+type setStudentSectionRequest struct {
+	SectionID *uint `json:"sectionId"`
+}
+
+// SetStudentSection moves an enrolled student to another section, or out of
+// their section when sectionId is 0.
+func SetStudentSection(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		log.Println("set student section error: invalid user ID")
+		c.JSON(400, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	var req setStudentSectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.SectionID == nil {
+		log.Println("set student section error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	var sectionID *uint
+	if *req.SectionID != 0 {
+		var count int64
+		if err := database.DB.Model(&models.Section{}).Where("id = ? AND course_id = ?", *req.SectionID, course.ID).Count(&count).Error; err != nil {
+			log.Println("set student section error: failed to get section")
+			c.JSON(500, gin.H{"message": "Failed to get section"})
+			return
+		}
+		if count == 0 {
+			log.Println("set student section error: section not found")
+			c.JSON(404, gin.H{"message": "Section not found"})
+			return
+		}
+		sectionID = req.SectionID
+	}
+	var promoted []models.WaitlistEntry
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockCourseSeats(tx, course.ID); err != nil {
+			return err
+		}
+		enrollment := models.Enrollment{}
+		err := tx.Where("course_id = ? AND user_id = ? AND status = ?", course.ID, userID, models.EnrollmentActive).
+			First(&enrollment).Error
+		if err != nil {
+			return err
+		}
+		if sectionID != nil && (enrollment.SectionID == nil || *enrollment.SectionID != *sectionID) {
+			full, err := sectionFull(tx, *sectionID)
+			if err != nil {
+				return err
+			}
+			if full {
+				return errSectionFull
+			}
+		}
+		if err := tx.Model(&enrollment).Update("section_id", sectionID).Error; err != nil {
+			return err
+		}
+		// The old section may have room for a waitlisted student now.
+		promoted, err = promoteWaitlist(tx, course.ID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("set student section error: enrollment not found")
+			c.JSON(404, gin.H{"message": "Student is not enrolled in this course"})
+			return
+		}
+		if errors.Is(err, errSectionFull) {
+			log.Println("set student section error: section full")
+			c.JSON(409, gin.H{"message": "Section is full"})
+			return
+		}
+		log.Println("set student section error: failed to move student", err)
+		c.JSON(500, gin.H{"message": "Failed to move student"})
+		return
+	}
+	notifyPromoted(course, promoted)
+	log.Println("set student section success: student moved")
+	c.JSON(200, gin.H{
+		"message":   "Student moved successfully",
+		"sectionId": sectionID,
+	})
+}
+
+type messageStudentsRequest struct {
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	SectionID *uint  `json:"sectionId"`
+}
+
+// MessageStudents emails the course's active students, or only those in one
+// section.
+func MessageStudents(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	var req messageStudentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Println("message students error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	req.Subject = strings.TrimSpace(req.Subject)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Subject == "" || req.Body == "" {
+		log.Println("message students error: subject and body are required")
+		c.JSON(400, gin.H{"message": "Subject and body are required"})
+		return
+	}
+	if strings.ContainsFunc(req.Subject, unicode.IsControl) {
+		log.Println("message students error: control characters in subject")
+		c.JSON(400, gin.H{"message": "Subject must be a single line of text"})
+		return
+	}
+	allowed, err := ratelimit.Allow(fmt.Sprintf("course_message:%d", course.ID), 20, time.Hour)
+	if err != nil {
+		log.Println("message students error: failed to check rate limit", err)
+		c.JSON(500, gin.H{"message": "Failed to send message"})
+		return
+	}
+	if !allowed {
+		log.Println("message students error: rate limited")
+		c.JSON(429, gin.H{"message": "Too many messages, try again later"})
+		return
+	}
+	query := database.DB.Preload("User").Where("course_id = ? AND status = ?", course.ID, models.EnrollmentActive)
+	if req.SectionID != nil {
+		query = query.Where("section_id = ?", *req.SectionID)
+	}
+	enrollments := []models.Enrollment{}
+	if err := query.Find(&enrollments).Error; err != nil {
+		log.Println("message students error: failed to get enrollments")
+		c.JSON(500, gin.H{"message": "Failed to send message"})
+		return
+	}
+	sent := 0
+	for _, enrollment := range enrollments {
+		err := mailer.Send(mailer.Message{
+			To:      enrollment.User.Email,
+			Subject: fmt.Sprintf("[%s] %s", course.Code, req.Subject),
+			Body:    req.Body + "\n",
+		})
+		if err != nil {
+			log.Println("message students error: failed to send email", err)
+			continue
+		}
+		sent++
+	}
+	details := fmt.Sprintf("recipients=%d", sent)
+	if req.SectionID != nil {
+		details += fmt.Sprintf(" section=%d", *req.SectionID)
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.message",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    details,
+	})
+	log.Println("message students success: message sent")
+	c.JSON(200, gin.H{
+		"message": "Message sent",
+		"sent":    sent,
+		"failed":  len(enrollments) - sent,
+	})
+}
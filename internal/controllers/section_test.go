@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"conductor_backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMessageStudentsRejectsBadSubjects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// Bad subjects are refused before the rate limit and the database are
+	// reached, which these tests don't have.
+	tests := []struct {
+		name    string
+		subject string
+	}{
+		{name: "empty", subject: "   "},
+		{name: "header injection", subject: `Hello\r\nBcc: everyone@example.com`},
+		{name: "line feed", subject: `Hello\nthere`},
+		{name: "tab", subject: `Hello\tthere`},
+		{name: "null byte", subject: `Hello\u0000`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			body := `{"subject": "` + tt.subject + `", "body": "Class is cancelled"}`
+			c.Request = httptest.NewRequest("POST", "/courses/1/message", strings.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Set("course", models.Course{ID: 1, Code: "CS101"})
+
+			MessageStudents(c)
+
+			if w.Code != 400 {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type tokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// issueTokens starts a new session for user and writes the error response
+// itself when that fails.
+func issueTokens(c *gin.Context, user models.User, device string) (tokenPair, bool) {
+	userAgent := c.Request.UserAgent()
+	session, refreshToken, err := auth.CreateSession(user.ID, auth.ClientInfo{
+		Device:    deviceName(device, userAgent),
+		IP:        c.ClientIP(),
+		UserAgent: userAgent,
+	})
+	if err != nil {
+		log.Println("issue tokens error: failed to create session", err)
+		c.JSON(500, gin.H{"message": "Failed to create session"})
+		return tokenPair{}, false
+	}
+	accessToken, err := auth.IssueAccessToken(user, session.ID)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSigningKey) {
+			log.Println("issue tokens error: server misconfiguration")
+			c.JSON(500, gin.H{"message": "Server misconfiguration"})
+			return tokenPair{}, false
+		}
+		log.Println("issue tokens error: failed to create token")
+		c.JSON(500, gin.H{"message": "Failed to create token"})
+		return tokenPair{}, false
+	}
+	return tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(auth.AccessTokenTTL.Seconds()),
+	}, true
+}
+
+// respondLogin finishes a login whose first factor has been checked. Users
+// with two-factor enabled get an MFA challenge instead of tokens.
+func respondLogin(c *gin.Context, user models.User, device string) {
+	if user.MFAEnabled() {
+		challenge, err := auth.CreateMFAChallenge(user.ID)
+		if err != nil {
+			log.Println("login error: failed to create MFA challenge", err)
+			c.JSON(500, gin.H{"message": "Failed to log in"})
+			return
+		}
+		log.Println("login success: MFA challenge issued")
+		c.JSON(200, gin.H{
+			"mfaRequired": true,
+			"mfaToken":    challenge,
+			"expiresIn":   int64(auth.MFAChallengeTTL.Seconds()),
+		})
+		return
+	}
+	respondTokens(c, user, device)
+}
+
+func respondTokens(c *gin.Context, user models.User, device string) {
+	tokens, ok := issueTokens(c, user, device)
+	if !ok {
+		return
+	}
+	log.Println("login success: token created")
+	c.JSON(200, gin.H{
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
+		"user": gin.H{
+			"id":             user.ID,
+			"name":           user.Name,
+			"email":          user.Email,
+			"role":           user.Role,
+			"approvalStatus": user.ApprovalStatus,
+			"mfaEnabled":     user.MFAEnabled(),
+		},
+		"mfaSetupRequired": auth.MFARequired(user.Role) && !user.MFAEnabled(),
+	})
+}
+
+// deviceName prefers the name the client sent and otherwise guesses a coarse
+// one from the user agent.
+func deviceName(device string, userAgent string) string {
+	if device = strings.TrimSpace(device); device != "" {
+		if runes := []rune(device); len(runes) > 64 {
+			device = string(runes[:64])
+		}
+		return device
+	}
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"):
+		return "Tablet"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "android"), strings.Contains(ua, "mobile"):
+		return "Mobile"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os"):
+		return "Mac"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	case ua == "":
+		return "Unknown device"
+	}
+	return "Other"
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		log.Println("refresh error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	session, refreshToken, err := auth.RotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			log.Println("refresh error: refresh token reused, session revoked")
+			c.JSON(401, gin.H{"message": "Invalid refresh token"})
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			log.Println("refresh error: invalid refresh token")
+			c.JSON(401, gin.H{"message": "Invalid refresh token"})
+			return
+		}
+		log.Println("refresh error: failed to rotate refresh token", err)
+		c.JSON(500, gin.H{"message": "Failed to refresh token"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("id = ?", session.UserID).First(&user).Error; err != nil {
+		log.Println("refresh error: failed to get user")
+		auth.RevokeSession(session.ID)
+		c.JSON(401, gin.H{"message": "Invalid refresh token"})
+		return
+	}
+	accessToken, err := auth.IssueAccessToken(user, session.ID)
+	if err != nil {
+		log.Println("refresh error: failed to create token")
+		c.JSON(500, gin.H{"message": "Failed to create token"})
+		return
+	}
+	log.Println("refresh success: token refreshed")
+	c.JSON(200, gin.H{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    int64(auth.AccessTokenTTL.Seconds()),
+	})
+}
+
+func Logout(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		log.Println("logout error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	if err := auth.RevokeRefreshToken(req.RefreshToken); err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			log.Println("logout error: invalid refresh token")
+			c.JSON(401, gin.H{"message": "Invalid refresh token"})
+			return
+		}
+		log.Println("logout error: failed to revoke session", err)
+		c.JSON(500, gin.H{"message": "Failed to log out"})
+		return
+	}
+	log.Println("logout success: session revoked")
+	c.JSON(200, gin.H{"message": "Logged out successfully"})
+}
+
+func ListSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	sessions, err := auth.ListUserSessions(userID)
+	if err != nil {
+		log.Println("list sessions error: failed to get sessions", err)
+		c.JSON(500, gin.H{"message": "Failed to get sessions"})
+		return
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	currentID := c.GetString("sessionID")
+	result := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, gin.H{
+			"id":         session.ID,
+			"device":     session.Device,
+			"ip":         session.IP,
+			"userAgent":  session.UserAgent,
+			"createdAt":  session.CreatedAt,
+			"lastSeenAt": session.LastSeenAt,
+			"current":    session.ID == currentID,
+		})
+	}
+	log.Println("list sessions success: sessions found")
+	c.JSON(200, gin.H{"sessions": result})
+}
+
+func RevokeSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	session, err := auth.GetSession(sessionID)
+	if err != nil || session.UserID != c.GetUint("userID") {
+		log.Println("revoke session error: session not found")
+		c.JSON(404, gin.H{"message": "Session not found"})
+		return
+	}
+	if err := auth.RevokeSession(session.ID); err != nil {
+		log.Println("revoke session error: failed to revoke session", err)
+		c.JSON(500, gin.H{"message": "Failed to revoke session"})
+		return
+	}
+	log.Println("revoke session success: session revoked")
+	c.JSON(200, gin.H{"message": "Session revoked successfully"})
+}
+
+func RevokeAllSessions(c *gin.Context) {
+	if err := auth.RevokeUserSessions(c.GetUint("userID")); err != nil {
+		log.Println("revoke all sessions error: failed to revoke sessions", err)
+		c.JSON(500, gin.H{"message": "Failed to revoke sessions"})
+		return
+	}
+	log.Println("revoke all sessions success: sessions revoked")
+	c.JSON(200, gin.H{"message": "All sessions revoked successfully"})
+}
+
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, auth.JWKS())
+}
@@ -0,0 +1,427 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// staffRoles are the course roles a course's staff can hand out.
+var staffRoles = map[string]bool{
+	models.CourseRoleCoInstructor: true,
+	models.CourseRoleTA:           true,
+	models.CourseRoleGrader:       true,
+	models.CourseRoleAuditor:      true,
+}
+
+// isCourseStaff reports whether the user is the course's professor or holds
+// a role in it. Staff can't be enrolled as students.
+func isCourseStaff(db *gorm.DB, course models.Course, userID uint) (bool, error) {
+	if userID == course.ProfessorID {
+		return true, nil
+	}
+	var count int64
+	err := db.Model(&models.CourseRoleAssignment{}).
+		Where("course_id = ? AND user_id = ?", course.ID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func ListCourseStaff(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	professor := models.User{}
+	if err := database.DB.Where("id = ?", course.ProfessorID).First(&professor).Error; err != nil {
+		log.Println("list course staff error: failed to get professor")
+		c.JSON(500, gin.H{"message": "Failed to get staff"})
+		return
+	}
+	assignments := []models.CourseRoleAssignment{}
+	err := database.DB.Preload("Role").
+		Where("course_id = ?", course.ID).
+		Order("created_at").
+		Find(&assignments).Error
+	if err != nil {
+		log.Println("list course staff error: failed to get staff")
+		c.JSON(500, gin.H{"message": "Failed to get staff"})
+		return
+	}
+	userIDs := make([]uint, 0, len(assignments))
+	for _, assignment := range assignments {
+		userIDs = append(userIDs, assignment.UserID)
+	}
+	users := []models.User{}
+	if len(userIDs) > 0 {
+		if err := database.DB.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			log.Println("list course staff error: failed to get users")
+			c.JSON(500, gin.H{"message": "Failed to get staff"})
+			return
+		}
+	}
+	byID := map[uint]models.User{}
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	staff := []gin.H{{
+		"userId":  professor.ID,
+		"name":    professor.Name,
+		"email":   professor.Email,
+		"role":    models.CourseRoleInstructor,
+		"addedAt": course.CreatedAt,
+	}}
+	for _, assignment := range assignments {
+		user := byID[assignment.UserID]
+		staff = append(staff, gin.H{
+			"userId":  assignment.UserID,
+			"name":    user.Name,
+			"email":   user.Email,
+			"role":    assignment.Role.Name,
+			"addedAt": assignment.CreatedAt,
+		})
+	}
+	invitations := []models.StaffInvitation{}
+	err = database.DB.Preload("Role").Preload("User").
+		Where("course_id = ?", course.ID).
+		Order("created_at").
+		Find(&invitations).Error
+	if err != nil {
+		log.Println("list course staff error: failed to get invitations")
+		c.JSON(500, gin.H{"message": "Failed to get staff"})
+		return
+	}
+	pending := make([]gin.H, 0, len(invitations))
+	for _, invitation := range invitations {
+		pending = append(pending, gin.H{
+			"invitationId": invitation.ID,
+			"userId":       invitation.UserID,
+			"name":         invitation.User.Name,
+			"email":        invitation.User.Email,
+			"role":         invitation.Role.Name,
+			"invitedAt":    invitation.CreatedAt,
+		})
+	}
+	log.Println("list course staff success: staff found")
+	c.JSON(200, gin.H{"staff": staff, "pending": pending})
+}
+
+type addCourseStaffRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AddCourseStaff offers a user a staff role in the course, replacing any
+// offer they already had there. The role is granted when they accept it with
+// AcceptStaffInvitation. Only verified accounts can be offered a role, and
+// only approved professors can co-instruct.
+func AddCourseStaff(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	var req addCourseStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		log.Println("add course staff error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	if !staffRoles[req.Role] {
+		log.Println("add course staff error: invalid role")
+		c.JSON(400, gin.H{"message": "Invalid role"})
+		return
+	}
+	user := models.User{}
+	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("add course staff error: user not found")
+			c.JSON(404, gin.H{"message": "User not found"})
+			return
+		}
+		log.Println("add course staff error: failed to get user")
+		c.JSON(500, gin.H{"message": "Failed to get user"})
+		return
+	}
+	if user.ID == course.ProfessorID {
+		log.Println("add course staff error: user is the course professor")
+		c.JSON(400, gin.H{"message": "User already teaches this course"})
+		return
+	}
+	if !user.IsVerified() {
+		log.Println("add course staff error: user not verified")
+		c.JSON(400, gin.H{"message": "User has not verified their email"})
+		return
+	}
+	if req.Role == models.CourseRoleCoInstructor && !user.IsApprovedProfessor() {
+		log.Println("add course staff error: user is not a professor")
+		c.JSON(400, gin.H{"message": "Only approved professors can be co-instructors"})
+		return
+	}
+	role := models.AccessRole{}
+	if err := database.DB.Where("name = ? AND scope = ?", req.Role, models.RoleScopeCourse).First(&role).Error; err != nil {
+		log.Println("add course staff error: role not found")
+		c.JSON(500, gin.H{"message": "Failed to get role"})
+		return
+	}
+	actorID := c.GetUint("userID")
+	invitation := models.StaffInvitation{
+		CourseID:  course.ID,
+		UserID:    user.ID,
+		RoleID:    role.ID,
+		InvitedBy: actorID,
+		CreatedAt: time.Now(),
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("course_id = ? AND user_id = ?", course.ID, user.ID).Delete(&models.StaffInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		log.Println("add course staff error: failed to save invitation", err)
+		c.JSON(500, gin.H{"message": "Failed to add staff"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    actorID,
+		Action:     "course.staff.invite",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("user=%d role=%s", user.ID, role.Name),
+	})
+	err = mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("You were invited to join the staff of %s", course.Name),
+		Body: fmt.Sprintf("Hi %s,\n\nYou were invited to join %s (%s) as %s. Sign in to accept or decline:\n\n%s\n",
+			user.Name, course.Name, course.Code, role.Name, appURL()+"/staff-invitations"),
+	})
+	if err != nil {
+		log.Println("add course staff error: failed to send email", err)
+	}
+	log.Println("add course staff success: staff invited")
+	c.JSON(202, gin.H{
+		"invitationId": invitation.ID,
+		"userId":       user.ID,
+		"name":         user.Name,
+		"email":        user.Email,
+		"role":         role.Name,
+		"invitedAt":    invitation.CreatedAt,
+	})
+}
+
+// ListStaffInvitations lists the staff roles offered to the current user.
+func ListStaffInvitations(c *gin.Context) {
+	invitations := []models.StaffInvitation{}
+	err := database.DB.Preload("Role").Preload("Course").
+		Joins("JOIN courses ON courses.id = staff_invitations.course_id AND courses.deleted_at IS NULL").
+		Where("staff_invitations.user_id = ?", c.GetUint("userID")).
+		Order("staff_invitations.created_at").
+		Find(&invitations).Error
+	if err != nil {
+		log.Println("list staff invitations error: failed to get invitations")
+		c.JSON(500, gin.H{"message": "Failed to get invitations"})
+		return
+	}
+	result := make([]gin.H, 0, len(invitations))
+	for _, invitation := range invitations {
+		result = append(result, gin.H{
+			"id":         invitation.ID,
+			"courseId":   invitation.CourseID,
+			"courseName": invitation.Course.Name,
+			"courseCode": invitation.Course.Code,
+			"role":       invitation.Role.Name,
+			"invitedAt":  invitation.CreatedAt,
+		})
+	}
+	log.Println("list staff invitations success: invitations found")
+	c.JSON(200, gin.H{"invitations": result})
+}
+
+// loadStaffInvitation loads the current user's :invitationId offer,
+// answering the request itself when it can't.
+func loadStaffInvitation(c *gin.Context, action string) (models.StaffInvitation, bool) {
+	invitation := models.StaffInvitation{}
+	id, err := strconv.ParseUint(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		log.Println(action + " error: invalid invitation ID")
+		c.JSON(400, gin.H{"message": "Invalid invitation ID"})
+		return invitation, false
+	}
+	err = database.DB.Preload("Role").Preload("Course").
+		Where("id = ? AND user_id = ?", id, c.GetUint("userID")).
+		First(&invitation).Error
+	if err == nil && invitation.Course.ID == 0 {
+		// The course is in the trash.
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println(action + " error: invitation not found")
+			c.JSON(404, gin.H{"message": "Invitation not found"})
+			return invitation, false
+		}
+		log.Println(action + " error: failed to get invitation")
+		c.JSON(500, gin.H{"message": "Failed to get invitation"})
+		return invitation, false
+	}
+	return invitation, true
+}
+
+// AcceptStaffInvitation grants the current user the offered course role,
+// replacing any staff role they already had in the course.
+// errStudentInCourse stops a student who is enrolled or waitlisted in a
+// course from also joining its staff.
+var errStudentInCourse = errors.New("user is a student in the course")
+
+func AcceptStaffInvitation(c *gin.Context) {
+	invitation, ok := loadStaffInvitation(c, "accept staff invitation")
+	if !ok {
+		return
+	}
+	if invitation.Course.Archived() {
+		log.Println("accept staff invitation error: course archived")
+		c.JSON(409, gin.H{"message": "Course is archived"})
+		return
+	}
+	assignment := models.CourseRoleAssignment{
+		CourseID:  invitation.CourseID,
+		UserID:    invitation.UserID,
+		RoleID:    invitation.RoleID,
+		CreatedAt: time.Now(),
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Under the course lock no join can enroll the user meanwhile.
+		if _, err := lockCourseSeats(tx, invitation.CourseID); err != nil {
+			return err
+		}
+		enrolled, err := isEnrolled(tx, invitation.CourseID, invitation.UserID)
+		if err != nil {
+			return err
+		}
+		waitlisted, err := isWaitlisted(tx, invitation.CourseID, invitation.UserID)
+		if err != nil {
+			return err
+		}
+		if enrolled || waitlisted {
+			return errStudentInCourse
+		}
+		// Deleting the offer first means a concurrent accept finds nothing.
+		result := tx.Where("id = ?", invitation.ID).Delete(&models.StaffInvitation{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("course_id = ? AND user_id = ?", invitation.CourseID, invitation.UserID).Delete(&models.CourseRoleAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&assignment).Error
+	})
+	if err != nil {
+		if errors.Is(err, errStudentInCourse) {
+			log.Println("accept staff invitation error: user is a student in the course")
+			c.JSON(409, gin.H{"message": "Leave the course as a student before joining its staff"})
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("accept staff invitation error: invitation not found")
+			c.JSON(404, gin.H{"message": "Invitation not found"})
+			return
+		}
+		log.Println("accept staff invitation error: failed to save assignment", err)
+		c.JSON(500, gin.H{"message": "Failed to accept invitation"})
+		return
+	}
+	auth.InvalidatePermissions(invitation.UserID)
+	audit.Record(models.AuditEvent{
+		ActorID:    invitation.UserID,
+		Action:     "course.staff.add",
+		TargetType: "course",
+		TargetID:   invitation.CourseID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("user=%d role=%s invitedBy=%d", invitation.UserID, invitation.Role.Name, invitation.InvitedBy),
+	})
+	log.Println("accept staff invitation success: staff added")
+	c.JSON(200, gin.H{
+		"courseId": invitation.CourseID,
+		"role":     invitation.Role.Name,
+		"addedAt":  assignment.CreatedAt,
+	})
+}
+
+func DeclineStaffInvitation(c *gin.Context) {
+	invitation, ok := loadStaffInvitation(c, "decline staff invitation")
+	if !ok {
+		return
+	}
+	if err := database.DB.Delete(&invitation).Error; err != nil {
+		log.Println("decline staff invitation error: failed to delete invitation")
+		c.JSON(500, gin.H{"message": "Failed to decline invitation"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    invitation.UserID,
+		Action:     "course.staff.decline",
+		TargetType: "course",
+		TargetID:   invitation.CourseID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("user=%d role=%s", invitation.UserID, invitation.Role.Name),
+	})
+	log.Println("decline staff invitation success: invitation declined")
+	c.JSON(200, gin.H{"message": "Invitation declined"})
+}
+
+// RemoveCourseStaff takes away a user's staff role in the course, or
+// withdraws the role offered to them.
+func RemoveCourseStaff(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		log.Println("remove course staff error: invalid user ID")
+		c.JSON(400, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	var removed int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("course_id = ? AND user_id = ?", course.ID, userID).Delete(&models.CourseRoleAssignment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		result = tx.Where("course_id = ? AND user_id = ?", course.ID, userID).Delete(&models.StaffInvitation{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed += result.RowsAffected
+		sectionIDs := tx.Model(&models.Section{}).Select("id").Where("course_id = ?", course.ID)
+		return tx.Where("user_id = ? AND section_id IN (?)", userID, sectionIDs).Delete(&models.SectionStaff{}).Error
+	})
+	if err != nil {
+		log.Println("remove course staff error: failed to delete assignment")
+		c.JSON(500, gin.H{"message": "Failed to remove staff"})
+		return
+	}
+	if removed == 0 {
+		log.Println("remove course staff error: staff not found")
+		c.JSON(404, gin.H{"message": "Staff member not found"})
+		return
+	}
+	auth.InvalidatePermissions(uint(userID))
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "course.staff.remove",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("user=%d", userID),
+	})
+	log.Println("remove course staff success: staff removed")
+	c.JSON(200, gin.H{"message": "Staff member removed successfully"})
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/jobs"
+	"conductor_backend/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func termResponse(term models.Term) gin.H {
+	return gin.H{
+		"id":        term.ID,
+		"name":      term.Name,
+		"startDate": term.StartDate.Format(models.TermDateLayout),
+		"endDate":   term.EndDate.Format(models.TermDateLayout),
+		"ended":     term.Ended(time.Now()),
+	}
+}
+
+func ListTerms(c *gin.Context) {
+	terms := []models.Term{}
+	if err := database.DB.Order("start_date DESC").Find(&terms).Error; err != nil {
+		log.Println("list terms error: failed to get terms")
+		c.JSON(500, gin.H{"message": "Failed to get terms"})
+		return
+	}
+	result := make([]gin.H, 0, len(terms))
+	for _, term := range terms {
+		result = append(result, termResponse(term))
+	}
+	log.Println("list terms success: terms found")
+	c.JSON(200, gin.H{"terms": result})
+}
+
+type termRequest struct {
+	Name      *string `json:"name"`
+	StartDate *string `json:"startDate"`
+	EndDate   *string `json:"endDate"`
+}
+
+// applyTermRequest copies the provided fields onto term and returns an error
+// message per invalid field.
+func applyTermRequest(term *models.Term, req termRequest) map[string]string {
+	fieldErrors := map[string]string{}
+	if req.Name != nil {
+		term.Name = strings.TrimSpace(*req.Name)
+		if term.Name == "" {
+			fieldErrors["name"] = "Name cannot be empty"
+		}
+	}
+	if req.StartDate != nil {
+		date, err := time.Parse(models.TermDateLayout, *req.StartDate)
+		if err != nil {
+			fieldErrors["startDate"] = "Dates must look like 2025-01-31"
+		}
+		term.StartDate = date
+	}
+	if req.EndDate != nil {
+		date, err := time.Parse(models.TermDateLayout, *req.EndDate)
+		if err != nil {
+			fieldErrors["endDate"] = "Dates must look like 2025-01-31"
+		}
+		term.EndDate = date
+	}
+	if len(fieldErrors) == 0 && term.EndDate.Before(term.StartDate) {
+		fieldErrors["endDate"] = "End date cannot be before the start date"
+	}
+	return fieldErrors
+}
+
+func CreateTerm(c *gin.Context) {
+	var req termRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil || req.StartDate == nil || req.EndDate == nil {
+		log.Println("create term error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	term := models.Term{CreatedAt: time.Now()}
+	if fieldErrors := applyTermRequest(&term, req); len(fieldErrors) > 0 {
+		log.Println("create term error: invalid fields")
+		c.JSON(400, gin.H{"message": "Invalid term", "errors": fieldErrors})
+		return
+	}
+	if err := database.DB.Create(&term).Error; err != nil {
+		if isUniqueViolation(err) {
+			log.Println("create term error: name already in use")
+			c.JSON(409, gin.H{"message": "Term name already in use"})
+			return
+		}
+		log.Println("create term error: failed to create term", err)
+		c.JSON(500, gin.H{"message": "Failed to create term"})
+		return
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "term.create",
+		TargetType: "term",
+		TargetID:   term.ID,
+		IP:         c.ClientIP(),
+	})
+	log.Println("create term success: term created")
+	c.JSON(201, termResponse(term))
+}
+
+// UpdateTerm changes a term's name or dates. Moving the end date into the
+// past archives the term's courses right away.
+func UpdateTerm(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		log.Println("update term error: invalid term ID")
+		c.JSON(400, gin.H{"message": "Invalid term ID"})
+		return
+	}
+	var req termRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Println("update term error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	term := models.Term{}
+	if err := database.DB.First(&term, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("update term error: term not found")
+			c.JSON(404, gin.H{"message": "Term not found"})
+			return
+		}
+		log.Println("update term error: failed to get term")
+		c.JSON(500, gin.H{"message": "Failed to get term"})
+		return
+	}
+	if fieldErrors := applyTermRequest(&term, req); len(fieldErrors) > 0 {
+		log.Println("update term error: invalid fields")
+		c.JSON(400, gin.H{"message": "Invalid term", "errors": fieldErrors})
+		return
+	}
+	err = database.DB.Model(&term).Updates(map[string]interface{}{
+		"name":       term.Name,
+		"start_date": term.StartDate,
+		"end_date":   term.EndDate,
+	}).Error
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Println("update term error: name already in use")
+			c.JSON(409, gin.H{"message": "Term name already in use"})
+			return
+		}
+		log.Println("update term error: failed to update term", err)
+		c.JSON(500, gin.H{"message": "Failed to update term"})
+		return
+	}
+	if err := jobs.ArchiveEndedTerms(time.Now()); err != nil {
+		log.Println("update term error: failed to archive courses", err)
+	}
+	audit.Record(models.AuditEvent{
+		ActorID:    c.GetUint("userID"),
+		Action:     "term.update",
+		TargetType: "term",
+		TargetID:   term.ID,
+		IP:         c.ClientIP(),
+		Details:    fmt.Sprintf("start=%s end=%s", term.StartDate.Format(models.TermDateLayout), term.EndDate.Format(models.TermDateLayout)),
+	})
+	log.Println("update term success: term updated")
+	c.JSON(200, termResponse(term))
+}
+
+// findOpenTerm loads a term courses can still be added to, answering the
+// request itself when it can't.
+func findOpenTerm(c *gin.Context, action string, termID uint) (models.Term, bool) {
+	term := models.Term{}
+	if err := database.DB.First(&term, termID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println(action + " error: term not found")
+			c.JSON(400, gin.H{"message": "Invalid course", "errors": gin.H{"termId": "Term not found"}})
+			return term, false
+		}
+		log.Println(action + " error: failed to get term")
+		c.JSON(500, gin.H{"message": "Failed to get term"})
+		return term, false
+	}
+	if term.Ended(time.Now()) {
+		log.Println(action + " error: term has ended")
+		c.JSON(400, gin.H{"message": "Invalid course", "errors": gin.H{"termId": "Term has already ended"}})
+		return term, false
+	}
+	return term, true
+}
+
+// courseListScope applies the termId and state query parameters shared by
+// the course list endpoints and returns the state. state is active
+// (default), archived or all.
+func courseListScope(c *gin.Context) (func(*gorm.DB) *gorm.DB, string, error) {
+	state := c.DefaultQuery("state", "active")
+	if state != "active" && state != "archived" && state != "all" {
+		return nil, "", errors.New("state must be active, archived or all")
+	}
+	var termID uint64
+	if raw := c.Query("termId"); raw != "" {
+		var err error
+		termID, err = strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, "", errors.New("Invalid termId")
+		}
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		switch state {
+		case "active":
+			db = db.Where("courses.archived_at IS NULL")
+		case "archived":
+			db = db.Where("courses.archived_at IS NOT NULL")
+		}
+		if termID != 0 {
+			db = db.Where("courses.term_id = ?", termID)
+		}
+		return db
+	}, state, nil
+}
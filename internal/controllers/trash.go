@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/jobs"
+	"conductor_backend/internal/models"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errCourseCodeTaken = errors.New("course code taken")
+
+// trashCourse soft-deletes the course together with its enrollments. The
+// waitlist is cleared and pending join requests are denied, since neither
+// can be served while the course is in the trash.
+func trashCourse(courseID uint) (bool, error) {
+	deleted := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Course{}, courseID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = true
+		if err := tx.Where("course_id = ?", courseID).Delete(&models.Enrollment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("course_id = ?", courseID).Delete(&models.WaitlistEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.JoinRequest{}).
+			Where("course_id = ? AND status = ?", courseID, models.JoinRequestPending).
+			Updates(map[string]interface{}{
+				"status":     models.JoinRequestDenied,
+				"decided_at": time.Now(),
+				"reason":     "The course was deleted",
+			}).Error
+	})
+	if deleted && err == nil {
+		auth.InvalidateCoursePermissions(courseID)
+	}
+	return deleted, err
+}
+
+// ListTrash lists deleted courses the user can restore, most recently
+// deleted first, with the date each will be purged. Users holding
+// course.delete globally see every course; others see the courses they hold
+// it on, which excludes professors who are restricted.
+func ListTrash(c *gin.Context) {
+	userID := c.GetUint("userID")
+	grants, err := auth.ResolvePermissions(userID, 0)
+	if err != nil {
+		log.Println("list trash error: failed to check permissions")
+		c.JSON(500, gin.H{"message": "Failed to check permissions"})
+		return
+	}
+	query := database.DB.Unscoped().Where("deleted_at IS NOT NULL")
+	if !grants.Has(models.PermCourseDelete) {
+		query = query.Where("professor_id = ?", userID)
+	}
+	deleted := []models.Course{}
+	if err := query.Order("deleted_at DESC").Find(&deleted).Error; err != nil {
+		log.Println("list trash error: failed to get courses")
+		c.JSON(500, gin.H{"message": "Failed to get deleted courses"})
+		return
+	}
+	courses := []models.Course{}
+	courseIDs := []uint{}
+	for _, course := range deleted {
+		if !grants.Has(models.PermCourseDelete) {
+			courseGrants, err := auth.ResolvePermissions(userID, course.ID)
+			if err != nil {
+				log.Println("list trash error: failed to check permissions")
+				c.JSON(500, gin.H{"message": "Failed to check permissions"})
+				return
+			}
+			if !courseGrants.Has(models.PermCourseDelete) {
+				continue
+			}
+		}
+		courses = append(courses, course)
+		courseIDs = append(courseIDs, course.ID)
+	}
+	var counts []struct {
+		CourseID uint
+		Count    int64
+	}
+	if len(courseIDs) > 0 {
+		err := database.DB.Unscoped().Model(&models.Enrollment{}).
+			Select("course_id, COUNT(*) AS count").
+			Where("course_id IN ? AND status = ?", courseIDs, models.EnrollmentActive).
+			Group("course_id").
+			Scan(&counts).Error
+		if err != nil {
+			log.Println("list trash error: failed to count enrollments")
+			c.JSON(500, gin.H{"message": "Failed to get deleted courses"})
+			return
+		}
+	}
+	enrolled := map[uint]int64{}
+	for _, count := range counts {
+		enrolled[count.CourseID] = count.Count
+	}
+	retention := jobs.TrashRetention()
+	result := make([]gin.H, 0, len(courses))
+	for _, course := range courses {
+		result = append(result, gin.H{
+			"id":          course.ID,
+			"name":        course.Name,
+			"code":        course.Code,
+			"professorID": course.ProfessorID,
+			"enrollments": enrolled[course.ID],
+			"deletedAt":   course.DeletedAt.Time,
+			"purgeAt":     course.DeletedAt.Time.Add(retention),
+		})
+	}
+	log.Println("list trash success: courses found")
+	c.JSON(200, gin.H{"courses": result})
+}
+
+// RestoreCourse brings a deleted course back with its enrollments. It fails
+// with 409 when another course has taken its code in the meantime.
+func RestoreCourse(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	userID := c.GetUint("userID")
+	var restored int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.Course{}).Where("code = ?", course.Code).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return errCourseCodeTaken
+		}
+		result := tx.Unscoped().Model(&course).Where("deleted_at IS NOT NULL").UpdateColumn("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Purged or restored by someone else meanwhile.
+			return gorm.ErrRecordNotFound
+		}
+		result = tx.Unscoped().Model(&models.Enrollment{}).
+			Where("course_id = ? AND deleted_at IS NOT NULL", course.ID).
+			UpdateColumn("deleted_at", nil)
+		restored = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("restore course error: course not in trash")
+			c.JSON(404, gin.H{"message": "Course not found in trash"})
+			return
+		}
+		if errors.Is(err, errCourseCodeTaken) || isUniqueViolation(err) {
+			log.Println("restore course error: code already in use")
+			c.JSON(409, gin.H{"message": "Course code already in use, change the other course's code first"})
+			return
+		}
+		log.Println("restore course error: failed to restore course", err)
+		c.JSON(500, gin.H{"message": "Failed to restore course"})
+		return
+	}
+	auth.InvalidateCoursePermissions(course.ID)
+	audit.Record(models.AuditEvent{
+		ActorID:    userID,
+		Action:     "course.restore",
+		TargetType: "course",
+		TargetID:   course.ID,
+		IP:         c.ClientIP(),
+	})
+	log.Println("restore course success: course restored")
+	c.JSON(200, gin.H{
+		"message":     "Course restored successfully",
+		"id":          course.ID,
+		"enrollments": restored,
+	})
+}
@@ -1,17 +1,20 @@
 package controllers
 
 import (
+	"conductor_backend/internal/audit"
+	"conductor_backend/internal/auth"
 	"conductor_backend/internal/database"
 	"conductor_backend/internal/models"
 	"encoding/json"
 	"fmt"
 	"log"
-	"time"
-
+	"math"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,10 +25,31 @@ type registerRequest struct {
 	Role     int8   `json:"role"`
 }
 
+// isFacultyEmail reports whether email belongs to one of the domains in
+// FACULTY_EMAIL_DOMAINS, including their subdomains.
+func isFacultyEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, faculty := range strings.Split(os.Getenv("FACULTY_EMAIL_DOMAINS"), ",") {
+		faculty = strings.ToLower(strings.TrimSpace(faculty))
+		if faculty == "" {
+			continue
+		}
+		if domain == faculty || strings.HasSuffix(domain, "."+faculty) {
+			return true
+		}
+	}
+	return false
+}
+
 type loginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Role     int8   `json:"role"`
+	Device   string `json:"device"`
 }
 
 func Register(c *gin.Context) {
@@ -44,9 +68,27 @@ func Register(c *gin.Context) {
 		})
 		return
 	}
+	if !validEmail(req.Email) {
+		log.Println("register error: invalid email")
+		c.JSON(400, gin.H{
+			"message": "Invalid email address",
+		})
+		return
+	}
 	if req.Role == 0 {
 		req.Role = models.RoleStudent
 	}
+	if req.Role != models.RoleStudent && req.Role != models.RoleProfessor {
+		log.Println("register error: invalid role")
+		c.JSON(400, gin.H{
+			"message": "Invalid role",
+		})
+		return
+	}
+	approvalStatus := models.ApprovalApproved
+	if req.Role == models.RoleProfessor && !isFacultyEmail(req.Email) {
+		approvalStatus = models.ApprovalPending
+	}
 	if req.Name == "" {
 		log.Println("register error: name is required")
 		c.JSON(400, gin.H{
@@ -66,11 +108,12 @@ func Register(c *gin.Context) {
 		return
 	}
 	user := models.User{
-		Email:        req.Email,
-		Name:         req.Name,
-		PasswordHash: string(hashed),
-		Role:         req.Role,
-		CreatedAt:    time.Now(),
+		Email:          req.Email,
+		Name:           req.Name,
+		PasswordHash:   string(hashed),
+		Role:           req.Role,
+		ApprovalStatus: approvalStatus,
+		CreatedAt:      time.Now(),
 	}
 	if err := database.DB.Create(&user).Error; err != nil {
 		log.Println("register error: failed to create user")
@@ -79,12 +122,17 @@ func Register(c *gin.Context) {
 		})
 		return
 	}
+	if err := sendVerificationEmail(user); err != nil {
+		log.Println("register error: failed to send verification email", err)
+	}
 	log.Println("register success: user created")
 	c.JSON(201, gin.H{
-		"id":    user.ID,
-		"email": user.Email,
-		"name":  user.Name,
-		"role":  user.Role,
+		"id":             user.ID,
+		"email":          user.Email,
+		"name":           user.Name,
+		"role":           user.Role,
+		"approvalStatus": user.ApprovalStatus,
+		"verified":       user.IsVerified(),
 	})
 }
 
@@ -112,54 +160,80 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if loginBlocked(c, req.Email) {
+		return
+	}
+
 	user := models.User{}
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		log.Println("login error: invalid email or password")
-		c.JSON(401, gin.H{
-			"message": "Invalid email or password",
-		})
+		// Spend the same time as a real check so response timing doesn't
+		// reveal whether the email exists.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		loginFailed(c, req.Email)
 		return
 	}
 	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
-		log.Println("login error: invalid email or password")
-		c.JSON(401, gin.H{
-			"message": "Invalid email or password",
-		})
+		loginFailed(c, req.Email)
 		return
 	}
+	if err := auth.ClearLoginFailures(req.Email); err != nil {
+		log.Println("login error: failed to clear login failures", err)
+	}
 
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		log.Println("login error: server misconfiguration")
-		c.JSON(500, gin.H{
-			"message": "Server misconfiguration",
+	if !user.IsVerified() {
+		log.Println("login error: email not verified")
+		c.JSON(403, gin.H{
+			"message": "Email not verified",
 		})
 		return
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  user.ID,
-		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
-	}).SignedString([]byte(secret))
+	respondLogin(c, user, req.Device)
+}
+
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("conductor"), bcrypt.DefaultCost)
+
+// loginBlocked answers 429 when the email or client IP is throttled. The
+// answer is the same whether or not the email has an account.
+func loginBlocked(c *gin.Context, email string) bool {
+	wait, err := auth.LoginRetryAfter(email, c.ClientIP())
 	if err != nil {
-		log.Println("login error: failed to create token")
+		log.Println("login error: failed to check login throttle", err)
 		c.JSON(500, gin.H{
-			"message": "Failed to create token",
+			"message": "Failed to log in",
 		})
-		return
+		return true
 	}
-	log.Println("login success: token created")
-	c.JSON(200, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"role":  user.Role,
-		},
+	if wait <= 0 {
+		return false
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	log.Println("login error: too many attempts")
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(429, gin.H{
+		"message":    "Too many login attempts, try again later",
+		"retryAfter": seconds,
 	})
+	return true
+}
 
+func loginFailed(c *gin.Context, email string) {
+	lockouts, err := auth.RecordLoginFailure(email, c.ClientIP())
+	if err != nil {
+		log.Println("login error: failed to record login failure", err)
+	}
+	for _, lockout := range lockouts {
+		audit.Record(models.AuditEvent{
+			Action:     "login.lockout",
+			TargetType: "login",
+			IP:         c.ClientIP(),
+			Details:    fmt.Sprintf("scope=%s failures=%d duration=%s", lockout.Scope, lockout.Failures, lockout.Duration),
+		})
+	}
+	log.Println("login error: invalid email or password")
+	c.JSON(401, gin.H{
+		"message": "Invalid email or password",
+	})
 }
 
 type setNameRequest struct {
@@ -214,10 +288,11 @@ func Me(c *gin.Context) {
 		var user models.User
 		json.Unmarshal([]byte(val), &user)
 		c.JSON(200, gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"role":  user.Role,
+			"id":             user.ID,
+			"name":           user.Name,
+			"email":          user.Email,
+			"role":           user.Role,
+			"approvalStatus": user.ApprovalStatus,
 		})
 		return
 	}
@@ -232,9 +307,10 @@ func Me(c *gin.Context) {
 	database.RDB.Set(database.Ctx, key, bytes, 5*time.Minute)
 	log.Println("me success: userID found")
 	c.JSON(200, gin.H{
-		"id":    user.ID,
-		"name":  user.Name,
-		"email": user.Email,
-		"role":  user.Role,
+		"id":             user.ID,
+		"name":           user.Name,
+		"email":          user.Email,
+		"role":           user.Role,
+		"approvalStatus": user.ApprovalStatus,
 	})
 }
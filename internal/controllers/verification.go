@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"conductor_backend/internal/ratelimit"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validEmail accepts a bare address like "a@b.edu", not "Name <a@b.edu>".
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	return at > 0 && strings.Contains(email[at+1:], ".")
+}
+
+func sendVerificationEmail(user models.User) error {
+	token, err := auth.CreateEmailVerificationToken(user.ID)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Conductor email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm your email address to activate your account. The link expires in %d hours.\n\n%s\n",
+			user.Name, int(auth.EmailVerificationTTL.Hours()), appLink("/verify-email", token),
+		),
+	})
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func VerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		log.Println("verify email error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	userID, err := auth.ConsumeEmailVerificationToken(req.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidOneTimeToken) {
+			log.Println("verify email error: invalid or expired token")
+			c.JSON(400, gin.H{"message": "Invalid or expired token"})
+			return
+		}
+		log.Println("verify email error: failed to check token", err)
+		c.JSON(500, gin.H{"message": "Failed to verify email"})
+		return
+	}
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND verified_at IS NULL", userID).
+		Update("verified_at", time.Now())
+	if result.Error != nil {
+		log.Println("verify email error: failed to save user", result.Error)
+		c.JSON(500, gin.H{"message": "Failed to save user"})
+		return
+	}
+	database.RDB.Del(database.Ctx, fmt.Sprintf("user:%d", userID))
+	// Students invited before they had an account join those courses once
+	// they have shown they own the address.
+	joined := []uint{}
+	if result.RowsAffected == 1 {
+		user := models.User{}
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			log.Println("verify email error: failed to get user", err)
+		} else {
+			joined = append(joined, acceptPendingInvitations(user)...)
+		}
+	}
+	log.Println("verify email success: email verified")
+	c.JSON(200, gin.H{
+		"message":       "Email verified successfully",
+		"joinedCourses": joined,
+	})
+}
+
+type resendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+func ResendVerification(c *gin.Context) {
+	var req resendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" {
+		log.Println("resend verification error: invalid request")
+		c.JSON(400, gin.H{"message": "Invalid request"})
+		return
+	}
+	allowed, err := ratelimit.Allow("verify_resend:email:"+strings.ToLower(req.Email), 3, time.Hour)
+	if err == nil && allowed {
+		allowed, err = ratelimit.Allow("verify_resend:ip:"+c.ClientIP(), 10, time.Hour)
+	}
+	if err != nil {
+		log.Println("resend verification error: failed to check rate limit", err)
+		c.JSON(500, gin.H{"message": "Failed to send email"})
+		return
+	}
+	if !allowed {
+		log.Println("resend verification error: rate limited")
+		c.JSON(429, gin.H{"message": "Too many requests, try again later"})
+		return
+	}
+	response := gin.H{"message": "If the account needs verification, an email has been sent"}
+
+	user := models.User{}
+	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil || user.IsVerified() {
+		log.Println("resend verification: nothing to send")
+		c.JSON(200, response)
+		return
+	}
+	if err := sendVerificationEmail(user); err != nil {
+		log.Println("resend verification error: failed to send email", err)
+		c.JSON(500, gin.H{"message": "Failed to send email"})
+		return
+	}
+	log.Println("resend verification success: email sent")
+	c.JSON(200, response)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/mailer"
+	"conductor_backend/internal/models"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	joinEnrolled   = "enrolled"
+	joinWaitlisted = "waitlisted"
+)
+
+// lockCourseSeats locks the course row for the rest of the transaction, so
+// joins, leaves and promotions in the same course run one at a time and
+// always see each other's enrollments.
+func lockCourseSeats(tx *gorm.DB, courseID uint) (models.Course, error) {
+	course := models.Course{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "capacity").
+		First(&course, courseID).Error
+	return course, err
+}
+
+// enrollOrWaitlist enrolls the user, in sectionID if it is set, when the
+// course has a free seat and puts them at the end of the waitlist otherwise.
+// For waitlisted users it also returns their position. A full section fails
+// with errSectionFull. The checks run under the course lock, so concurrent
+// joins of the same student fail with errAlreadyEnrolled or
+// errAlreadyWaitlisted instead of creating duplicates.
+func enrollOrWaitlist(tx *gorm.DB, courseID uint, sectionID *uint, userID uint) (string, int64, error) {
+	course, err := lockCourseSeats(tx, courseID)
+	if err != nil {
+		return "", 0, err
+	}
+	if enrolled, err := isEnrolled(tx, courseID, userID); err != nil || enrolled {
+		if err == nil {
+			err = errAlreadyEnrolled
+		}
+		return "", 0, err
+	}
+	if waitlisted, err := isWaitlisted(tx, courseID, userID); err != nil || waitlisted {
+		if err == nil {
+			err = errAlreadyWaitlisted
+		}
+		return "", 0, err
+	}
+	if sectionID != nil {
+		full, err := sectionFull(tx, *sectionID)
+		if err != nil {
+			return "", 0, err
+		}
+		if full {
+			return "", 0, errSectionFull
+		}
+	}
+	if course.Capacity != nil {
+		enrolled, err := countActiveEnrollments(tx, courseID)
+		if err != nil {
+			return "", 0, err
+		}
+		if freeSeats(course.Capacity, enrolled) == 0 {
+			entry := models.WaitlistEntry{
+				CourseID:  courseID,
+				UserID:    userID,
+				SectionID: sectionID,
+				CreatedAt: time.Now(),
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return "", 0, err
+			}
+			var position int64
+			err := tx.Model(&models.WaitlistEntry{}).Where("course_id = ?", courseID).Count(&position).Error
+			return joinWaitlisted, position, err
+		}
+	}
+	return joinEnrolled, 0, activateEnrollment(tx, courseID, sectionID, userID)
+}
+
+// promoteWaitlist fills the course's free seats from the front of the
+// waitlist and returns the promoted entries. Students whose section is full
+// keep their place. Call it in the transaction that frees the seats.
+func promoteWaitlist(tx *gorm.DB, courseID uint) ([]models.WaitlistEntry, error) {
+	course, err := lockCourseSeats(tx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	free := int64(-1) // no limit
+	if course.Capacity != nil {
+		enrolled, err := countActiveEnrollments(tx, courseID)
+		if err != nil {
+			return nil, err
+		}
+		free = freeSeats(course.Capacity, enrolled)
+		if free == 0 {
+			return nil, nil
+		}
+	}
+	entries := []models.WaitlistEntry{}
+	if err := tx.Preload("User").Where("course_id = ?", courseID).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return fillSeats(entries, free,
+		func(sectionID uint) (bool, error) {
+			return sectionFull(tx, sectionID)
+		},
+		func(entry models.WaitlistEntry) error {
+			if err := tx.Delete(&entry).Error; err != nil {
+				return err
+			}
+			return activateEnrollment(tx, courseID, entry.SectionID, entry.UserID)
+		})
+}
+
+// freeSeats is the number of seats left in a course with capacity and
+// enrolled active students, or -1 when its capacity is unlimited.
+func freeSeats(capacity *int, enrolled int64) int64 {
+	if capacity == nil {
+		return -1
+	}
+	if free := int64(*capacity) - enrolled; free > 0 {
+		return free
+	}
+	return 0
+}
+
+// fillSeats promotes waitlist entries in order until free seats are taken,
+// or all of them when free is -1. Entries whose section is full are skipped
+// and keep their place.
+func fillSeats(entries []models.WaitlistEntry, free int64, sectionFull func(sectionID uint) (bool, error), promote func(entry models.WaitlistEntry) error) ([]models.WaitlistEntry, error) {
+	promoted := []models.WaitlistEntry{}
+	for _, entry := range entries {
+		if free == 0 {
+			break
+		}
+		if entry.SectionID != nil {
+			full, err := sectionFull(*entry.SectionID)
+			if err != nil {
+				return nil, err
+			}
+			if full {
+				continue
+			}
+		}
+		if err := promote(entry); err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, entry)
+		free--
+	}
+	return promoted, nil
+}
+
+func notifyPromoted(course models.Course, entries []models.WaitlistEntry) {
+	for _, entry := range entries {
+		err := mailer.Send(mailer.Message{
+			To:      entry.User.Email,
+			Subject: fmt.Sprintf("A seat opened up in %s", course.Name),
+			Body:    fmt.Sprintf("Hi %s,\n\nYou were on the waitlist for %s (%s) and are now enrolled.\n", entry.User.Name, course.Name, course.Code),
+		})
+		if err != nil {
+			log.Println("notify promoted error: failed to send email", err)
+		}
+	}
+}
+
+// waitlistPosition is the 1-based place of the entry in its course's line.
+func waitlistPosition(entry models.WaitlistEntry) (int64, error) {
+	var position int64
+	err := database.DB.Model(&models.WaitlistEntry{}).
+		Where("course_id = ? AND id <= ?", entry.CourseID, entry.ID).
+		Count(&position).Error
+	return position, err
+}
+
+func GetCourseWaitlist(c *gin.Context) {
+	course := c.MustGet("course").(models.Course)
+	entries := []models.WaitlistEntry{}
+	if err := database.DB.Preload("User").Where("course_id = ?", course.ID).Order("id").Find(&entries).Error; err != nil {
+		log.Println("get course waitlist error: failed to get waitlist")
+		c.JSON(500, gin.H{"message": "Failed to get waitlist"})
+		return
+	}
+	waitlist := make([]gin.H, 0, len(entries))
+	for i, entry := range entries {
+		waitlist = append(waitlist, gin.H{
+			"position":     i + 1,
+			"userId":       entry.UserID,
+			"name":         entry.User.Name,
+			"email":        entry.User.Email,
+			"waitlistedAt": entry.CreatedAt,
+		})
+	}
+	log.Println("get course waitlist success: waitlist found")
+	c.JSON(200, gin.H{
+		"capacity": course.Capacity,
+		"waitlist": waitlist,
+	})
+}
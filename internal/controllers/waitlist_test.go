@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"conductor_backend/internal/models"
+)
+
+func TestFreeSeats(t *testing.T) {
+	capacity := func(n int) *int {
+		return &n
+	}
+	tests := []struct {
+		name     string
+		capacity *int
+		enrolled int64
+		want     int64
+	}{
+		{name: "unlimited", enrolled: 1000, want: -1},
+		{name: "empty", capacity: capacity(30), want: 30},
+		{name: "seats left", capacity: capacity(30), enrolled: 28, want: 2},
+		{name: "full", capacity: capacity(30), enrolled: 30, want: 0},
+		{name: "over capacity after it was lowered", capacity: capacity(30), enrolled: 35, want: 0},
+		{name: "no seats", capacity: capacity(0), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freeSeats(tt.capacity, tt.enrolled); got != tt.want {
+				t.Errorf("freeSeats = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillSeats(t *testing.T) {
+	sectionA, sectionB := uint(1), uint(2)
+	// Users 1 to 5 in line, 2 and 4 for section A, 3 for section B.
+	entries := []models.WaitlistEntry{
+		{ID: 10, UserID: 1},
+		{ID: 11, UserID: 2, SectionID: &sectionA},
+		{ID: 12, UserID: 3, SectionID: &sectionB},
+		{ID: 13, UserID: 4, SectionID: &sectionA},
+		{ID: 14, UserID: 5},
+	}
+	tests := []struct {
+		name string
+		free int64
+		// fullSections are full to begin with.
+		fullSections map[uint]bool
+		// sectionSeats is how many students each limited section still
+		// takes.
+		sectionSeats map[uint]int
+		want         []uint
+	}{
+		{name: "no free seats", free: 0, want: []uint{}},
+		{name: "one seat goes to the front of the line", free: 1, want: []uint{1}},
+		{name: "seats go in order", free: 3, want: []uint{1, 2, 3}},
+		{name: "unlimited promotes everyone", free: -1, want: []uint{1, 2, 3, 4, 5}},
+		{name: "more seats than students", free: 10, want: []uint{1, 2, 3, 4, 5}},
+		{
+			name:         "full section keeps its place",
+			free:         2,
+			fullSections: map[uint]bool{sectionA: true},
+			want:         []uint{1, 3},
+		},
+		{
+			name:         "section fills up while promoting",
+			free:         -1,
+			sectionSeats: map[uint]int{sectionA: 1},
+			want:         []uint{1, 2, 3, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seats := map[uint]int{}
+			for id, n := range tt.sectionSeats {
+				seats[id] = n
+			}
+			full := func(sectionID uint) (bool, error) {
+				if tt.fullSections[sectionID] {
+					return true, nil
+				}
+				n, limited := seats[sectionID]
+				return limited && n == 0, nil
+			}
+			promote := func(entry models.WaitlistEntry) error {
+				if entry.SectionID != nil {
+					if _, limited := seats[*entry.SectionID]; limited {
+						seats[*entry.SectionID]--
+					}
+				}
+				return nil
+			}
+			promoted, err := fillSeats(entries, tt.free, full, promote)
+			if err != nil {
+				t.Fatalf("fillSeats: %v", err)
+			}
+			got := []uint{}
+			for _, entry := range promoted {
+				got = append(got, entry.UserID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("promoted users %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillSeatsStopsOnError(t *testing.T) {
+	failed := errors.New("failed")
+	section := uint(1)
+	entries := []models.WaitlistEntry{{ID: 1, UserID: 1}, {ID: 2, UserID: 2, SectionID: &section}}
+	tests := []struct {
+		name    string
+		full    func(uint) (bool, error)
+		promote func(models.WaitlistEntry) error
+	}{
+		{
+			name:    "promotion fails",
+			full:    func(uint) (bool, error) { return false, nil },
+			promote: func(models.WaitlistEntry) error { return failed },
+		},
+		{
+			name:    "section check fails",
+			full:    func(uint) (bool, error) { return false, failed },
+			promote: func(models.WaitlistEntry) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promoted, err := fillSeats(entries, -1, tt.full, tt.promote)
+			if !errors.Is(err, failed) || promoted != nil {
+				t.Errorf("fillSeats = (%v, %v), want (nil, %v)", promoted, err, failed)
+			}
+		})
+	}
+}
@@ -3,7 +3,10 @@ package database
 import (
 	"conductor_backend/internal/models"
 	"fmt"
+	"log"
 	"os"
+	"strings"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -23,11 +26,136 @@ func ConnectPostgreSQL() {
 		panic(err)
 	}
 	DB = db
-	DB.AutoMigrate(
+	// Accounts that predate email verification are treated as verified.
+	backfillVerified := !DB.Migrator().HasColumn(&models.User{}, "verified_at")
+	// Enrollments are unique per student and course. Keep the oldest of any
+	// duplicates so the index can be created.
+	if DB.Migrator().HasTable(&models.Enrollment{}) && !DB.Migrator().HasIndex(&models.Enrollment{}, "idx_enrollments_user_course") {
+		must(DB.Exec("DELETE FROM enrollments a USING enrollments b WHERE a.user_id = b.user_id AND a.course_id = b.course_id AND a.id > b.id"))
+	}
+	if DB.Migrator().HasTable(&models.Course{}) && !DB.Migrator().HasIndex(&models.Course{}, "idx_courses_code_active") {
+		dedupeCourseCodes()
+	}
+	err = DB.AutoMigrate(
 		&models.User{},
 		&models.Course{},
+		&models.Section{},
+		&models.SectionStaff{},
 		&models.Enrollment{},
+		&models.AuditEvent{},
+		&models.UserIdentity{},
+		&models.MFARecoveryCode{},
+		&models.AccessRole{},
+		&models.RolePermission{},
+		&models.CourseRoleAssignment{},
+		&models.StaffInvitation{},
+		&models.CourseRemoval{},
+		&models.JoinRequest{},
+		&models.WaitlistEntry{},
+		&models.Term{},
+		&models.Invitation{},
 	)
+	if err != nil {
+		panic(err)
+	}
+	if backfillVerified {
+		must(DB.Model(&models.User{}).Where("verified_at IS NULL").Update("verified_at", gorm.Expr("created_at")))
+	}
+	must(DB.Model(&models.Course{}).Unscoped().Where("updated_at IS NULL").UpdateColumn("updated_at", gorm.Expr("created_at")))
+	must(DB.Model(&models.Enrollment{}).Where("enrolled_at IS NULL").UpdateColumn("enrolled_at", gorm.Expr("created_at")))
+	// Courses deleted before enrollments could be soft-deleted take their
+	// enrollments to the trash with them.
+	must(DB.Exec("UPDATE enrollments SET deleted_at = courses.deleted_at FROM courses WHERE courses.id = enrollments.course_id AND courses.deleted_at IS NOT NULL AND enrollments.deleted_at IS NULL"))
+	must(DB.Model(&models.Invitation{}).Where("expires_at IS NULL").
+		UpdateColumn("expires_at", gorm.Expr("created_at + ? * interval '1 second'", int64(models.InvitationTTL.Seconds()))))
+	backfillJoinCodes()
+	seedRoles()
+}
+
+// must stops startup when a migration step fails, rather than serving from
+// a half-migrated schema.
+func must(result *gorm.DB) {
+	if result.Error != nil {
+		panic(result.Error)
+	}
+}
+
+// dedupeCourseCodes makes the codes of active courses unique so their index
+// can be created. Codes used to be free-form, so the oldest course keeps its
+// code and later ones get their id appended. Every rename is logged for the
+// professors to be told.
+func dedupeCourseCodes() {
+	duplicates := []models.Course{}
+	must(DB.Where("EXISTS (SELECT 1 FROM courses b WHERE b.code = courses.code AND b.deleted_at IS NULL AND b.id < courses.id)").
+		Order("id").
+		Find(&duplicates))
+	for _, course := range duplicates {
+		code := fmt.Sprintf("%s-%d", course.Code, course.ID)
+		must(DB.Model(&course).UpdateColumn("code", code))
+		log.Printf("database: course %d (professor %d) shared code %q, renamed to %q", course.ID, course.ProfessorID, course.Code, code)
+	}
+}
+
+// backfillJoinCodes gives courses created before join codes existed one.
+func backfillJoinCodes() {
+	courses := []models.Course{}
+	must(DB.Unscoped().Where("join_code IS NULL OR join_code = ''").Find(&courses))
+	for _, course := range courses {
+		code, err := models.NewJoinCode()
+		if err != nil {
+			panic(err)
+		}
+		must(DB.Unscoped().Model(&course).UpdateColumn("join_code", code))
+	}
+}
+
+func seedRoles() {
+	for _, def := range models.DefaultRoles {
+		role := models.AccessRole{}
+		err := DB.Where(models.AccessRole{Name: def.Name}).
+			Attrs(models.AccessRole{Scope: def.Scope, CreatedAt: time.Now()}).
+			FirstOrCreate(&role).Error
+		if err != nil {
+			panic(err)
+		}
+		for _, permission := range def.Permissions {
+			err := DB.Where(models.RolePermission{RoleID: role.ID, Permission: permission}).
+				FirstOrCreate(&models.RolePermission{}).Error
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// PromoteAdmins gives the admin role to the existing accounts listed in
+// ADMIN_EMAILS and returns the IDs of those that didn't have it, whose
+// cached user and permissions are stale. It runs once Redis is connected.
+func PromoteAdmins() []uint {
+	emails := []string{}
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+	userIDs := []uint{}
+	must(DB.Model(&models.User{}).
+		Where("email IN ? AND (role <> ? OR approval_status <> ?)", emails, models.RoleAdmin, models.ApprovalApproved).
+		Pluck("id", &userIDs))
+	if len(userIDs) == 0 {
+		return nil
+	}
+	must(DB.Model(&models.User{}).Where("id IN ?", userIDs).Updates(map[string]interface{}{
+		"role":            models.RoleAdmin,
+		"approval_status": models.ApprovalApproved,
+	}))
+	for _, userID := range userIDs {
+		RDB.Del(Ctx, fmt.Sprintf("user:%d", userID))
+	}
+	return userIDs
 }
 
 func getEnv(key, defaultVal string) string {
@@ -0,0 +1,172 @@
+package jobs
+
+import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	archiveInterval = time.Hour
+	purgeInterval   = time.Hour
+
+	defaultTrashRetentionDays = 30
+)
+
+// Start runs the periodic jobs in the background. Every job is safe to run
+// on several servers at once.
+func Start() {
+	go every(archiveInterval, func() {
+		if err := ArchiveEndedTerms(time.Now()); err != nil {
+			log.Println("archive courses error:", err)
+		}
+	})
+	go every(purgeInterval, func() {
+		if err := PurgeTrash(time.Now()); err != nil {
+			log.Println("purge trash error:", err)
+		}
+	})
+}
+
+// TrashRetention is how long deleted courses stay restorable, set in days by
+// COURSE_TRASH_RETENTION_DAYS.
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("COURSE_TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// trashDependents are the tables with rows that belong to a course and go
+// when it is purged.
+var trashDependents = []interface{}{
+	&models.Enrollment{},
+	&models.WaitlistEntry{},
+	&models.JoinRequest{},
+	&models.CourseRemoval{},
+	&models.CourseRoleAssignment{},
+	&models.StaffInvitation{},
+	&models.Invitation{},
+}
+
+// PurgeTrash permanently deletes courses that have been in the trash longer
+// than TrashRetention, along with everything that belongs to them.
+func PurgeTrash(now time.Time) error {
+	cutoff := now.Add(-TrashRetention())
+	courseIDs := []uint{}
+	err := database.DB.Unscoped().Model(&models.Course{}).
+		Where("deleted_at < ?", cutoff).
+		Pluck("id", &courseIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, courseID := range courseIDs {
+		if err := purgeCourse(courseID, cutoff); err != nil {
+			return err
+		}
+	}
+	if len(courseIDs) > 0 {
+		log.Println("purge trash success: purged", len(courseIDs), "courses")
+	}
+	return nil
+}
+
+func purgeCourse(courseID uint, cutoff time.Time) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the course and make sure it wasn't restored since it was
+		// listed.
+		var count int64
+		err := tx.Unscoped().Model(&models.Course{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at < ?", courseID, cutoff).
+			Count(&count).Error
+		if err != nil || count == 0 {
+			return err
+		}
+		for _, model := range trashDependents {
+			if err := tx.Unscoped().Where("course_id = ?", courseID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		sectionIDs := tx.Model(&models.Section{}).Select("id").Where("course_id = ?", courseID)
+		if err := tx.Where("section_id IN (?)", sectionIDs).Delete(&models.SectionStaff{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("course_id = ?", courseID).Delete(&models.Section{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Course{}, courseID).Error
+	})
+}
+
+func every(interval time.Duration, job func()) {
+	for {
+		job()
+		time.Sleep(interval)
+	}
+}
+
+// ArchiveEndedTerms archives the courses whose term ended before now. Their
+// active enrollments are completed, the waitlist is cleared and pending join
+// requests are denied.
+func ArchiveEndedTerms(now time.Time) error {
+	ended := database.DB.Model(&models.Term{}).Select("id").Where("end_date < ?", now.Format(models.TermDateLayout))
+	courseIDs := []uint{}
+	err := database.DB.Model(&models.Course{}).
+		Where("archived_at IS NULL AND term_id IN (?)", ended).
+		Pluck("id", &courseIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, courseID := range courseIDs {
+		if err := archiveCourse(courseID, now); err != nil {
+			return err
+		}
+	}
+	if len(courseIDs) > 0 {
+		log.Println("archive courses success: archived", len(courseIDs), "courses")
+	}
+	return nil
+}
+
+func archiveCourse(courseID uint, now time.Time) error {
+	archived := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Course{}).
+			Where("id = ? AND archived_at IS NULL", courseID).
+			UpdateColumn("archived_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Another server archived it first.
+			return result.Error
+		}
+		archived = true
+		err := tx.Model(&models.Enrollment{}).
+			Where("course_id = ? AND status = ?", courseID, models.EnrollmentActive).
+			Updates(map[string]interface{}{"status": models.EnrollmentCompleted, "ended_at": now}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("course_id = ?", courseID).Delete(&models.WaitlistEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.JoinRequest{}).
+			Where("course_id = ? AND status = ?", courseID, models.JoinRequestPending).
+			Updates(map[string]interface{}{
+				"status":     models.JoinRequestDenied,
+				"decided_at": now,
+				"reason":     "The course has been archived",
+			}).Error
+	})
+	if archived && err == nil {
+		auth.InvalidateCoursePermissions(courseID)
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// LogMailer never sends anything. It logs each message and, if Dir is set,
+// also writes it to a file there so it can be inspected offline. Tokens in
+// links are redacted from the log, which often ends up in shared log
+// storage; only the files have the full links.
+type LogMailer struct {
+	Dir string
+}
+
+var linkToken = regexp.MustCompile(`([?&]token=)[^\s&]+`)
+
+func redactTokens(body string) string {
+	return linkToken.ReplaceAllString(body, "${1}[redacted]")
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	log.Printf("mailer: to=%s subject=%q\n%s", msg.To, msg.Subject, redactTokens(msg.Body))
+	if m.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.txt", time.Now().UnixNano(), msg.To)
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	return os.WriteFile(filepath.Join(m.Dir, filepath.Base(name)), []byte(content), 0o644)
+}
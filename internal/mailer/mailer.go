@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"log"
+	"os"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var Default Mailer
+
+// Init picks the mailer from MAILER: "smtp" sends real mail, anything else
+// writes messages to the log and, when MAIL_OUTBOX_DIR is set, to files.
+func Init() {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		Default = &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     getEnv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     getEnv("MAIL_FROM", "no-reply@conductor.local"),
+		}
+		log.Println("mailer: using smtp")
+	default:
+		Default = &LogMailer{Dir: os.Getenv("MAIL_OUTBOX_DIR")}
+		log.Println("mailer: using log sink")
+	}
+}
+
+func Send(msg Message) error {
+	if Default == nil {
+		Init()
+	}
+	return Default.Send(msg)
+}
+
+func getEnv(key, defaultVal string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultVal
+}
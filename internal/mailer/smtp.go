@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+)
+
+// ErrHeaderInjection is returned for a recipient or subject with a line
+// break, which would let it add headers of its own.
+var ErrHeaderInjection = errors.New("mail header contains a line break")
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := m.message(msg)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := fmt.Sprintf("%s:%s", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, data)
+}
+
+// message renders msg with its headers. The subject is Q-encoded so it may
+// hold any UTF-8 text.
+func (m *SMTPMailer) message(msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, ErrHeaderInjection
+	}
+	headers := []string{
+		"From: " + m.From,
+		"To: " + msg.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + msg.Body), nil
+}
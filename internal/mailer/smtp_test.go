@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSMTPMessage(t *testing.T) {
+	m := &SMTPMailer{From: "no-reply@conductor.local"}
+	tests := []struct {
+		name        string
+		msg         Message
+		wantSubject string
+		wantErr     error
+	}{
+		{
+			name:        "plain subject",
+			msg:         Message{To: "student@example.com", Subject: "[CS101] Welcome", Body: "Hi\n"},
+			wantSubject: "Subject: [CS101] Welcome\r\n",
+		},
+		{
+			name:        "non-ASCII subject is encoded",
+			msg:         Message{To: "student@example.com", Subject: "Bienvenue à CS101", Body: "Hi\n"},
+			wantSubject: "Subject: =?utf-8?q?Bienvenue_=C3=A0_CS101?=\r\n",
+		},
+		{
+			name:    "line break in subject",
+			msg:     Message{To: "student@example.com", Subject: "Hi\r\nBcc: everyone@example.com"},
+			wantErr: ErrHeaderInjection,
+		},
+		{
+			name:    "bare line feed in subject",
+			msg:     Message{To: "student@example.com", Subject: "Hi\nBcc: everyone@example.com"},
+			wantErr: ErrHeaderInjection,
+		},
+		{
+			name:    "line break in recipient",
+			msg:     Message{To: "student@example.com\r\nBcc: everyone@example.com", Subject: "Hi"},
+			wantErr: ErrHeaderInjection,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := m.message(tt.msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !strings.Contains(string(data), tt.wantSubject) {
+				t.Errorf("message %q has no %q", data, tt.wantSubject)
+			}
+		})
+	}
+}
@@ -1,11 +1,10 @@
 package middleware
 
 import (
-	"os"
+	"conductor_backend/internal/auth"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -30,17 +29,15 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-		if err != nil || !token.Valid {
+		claims, err := auth.ParseAccessToken(tokenString)
+		if err != nil {
 			c.JSON(401, gin.H{"message": "Invalid token"})
 			c.Abort()
 			return
 		}
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(401, gin.H{"message": "Invalid token"})
+		sessionID := claims["sid"].(string)
+		if _, err := auth.TouchSession(sessionID, c.ClientIP()); err != nil {
+			c.JSON(401, gin.H{"message": "Session revoked"})
 			c.Abort()
 			return
 		}
@@ -49,6 +46,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		c.Set("userID", userID)
 		c.Set("role", role)
+		c.Set("sessionID", sessionID)
 		c.Next()
 	}
 }
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"conductor_backend/internal/database"
+	"conductor_backend/internal/models"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadCourse loads the course named by the :id route parameter, answering
+// the request itself when it can't.
+func loadCourse(c *gin.Context) (models.Course, bool) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"message": "Invalid course ID"})
+		c.Abort()
+		return models.Course{}, false
+	}
+	course := models.Course{}
+	if err := database.DB.First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"message": "Course not found"})
+			c.Abort()
+			return models.Course{}, false
+		}
+		c.JSON(500, gin.H{"message": "Failed to get course"})
+		c.Abort()
+		return models.Course{}, false
+	}
+	return course, true
+}
+
+// loadTrashedCourse loads the deleted course named by the :id route
+// parameter, answering the request itself when it can't.
+func loadTrashedCourse(c *gin.Context) (models.Course, bool) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"message": "Invalid course ID"})
+		c.Abort()
+		return models.Course{}, false
+	}
+	course := models.Course{}
+	if err := database.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"message": "Course not found in trash"})
+			c.Abort()
+			return models.Course{}, false
+		}
+		c.JSON(500, gin.H{"message": "Failed to get course"})
+		c.Abort()
+		return models.Course{}, false
+	}
+	return course, true
+}
+
+// RequireActiveCourse rejects changes to archived courses. It must run after
+// RequirePermission, which loads the course.
+func RequireActiveCourse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course := c.MustGet("course").(models.Course)
+		if course.Archived() {
+			c.JSON(409, gin.H{"message": "Course is archived and read-only"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,30 +1,68 @@
 package middleware
 
 import (
+	"conductor_backend/internal/auth"
+	"conductor_backend/internal/database"
 	"conductor_backend/internal/models"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RequireProfessor() gin.HandlerFunc {
+// RequirePermission lets the request through only if the user holds every
+// listed permission. On /courses/:id and /courses/trash/:id routes the
+// permissions are resolved for that course, live or deleted respectively,
+// which is loaded and stored as "course".
+func RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleVal, _ := c.Get("role")
-		role := roleVal.(int8)
-		if role != models.RoleProfessor {
-			c.JSON(403, gin.H{"message": "Forbidden", "role": role, "shouldBe": models.RoleProfessor})
+		var courseID uint
+		var load func(*gin.Context) (models.Course, bool)
+		switch {
+		case strings.HasPrefix(c.FullPath(), "/courses/:id"):
+			load = loadCourse
+		case strings.HasPrefix(c.FullPath(), "/courses/trash/:id"):
+			load = loadTrashedCourse
+		}
+		if load != nil {
+			course, ok := load(c)
+			if !ok {
+				return
+			}
+			courseID = course.ID
+			c.Set("course", course)
+		}
+		grants, err := auth.ResolvePermissions(c.GetUint("userID"), courseID)
+		if err != nil {
+			c.JSON(500, gin.H{"message": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		for _, permission := range permissions {
+			if grants.Has(permission) {
+				continue
+			}
+			switch grants.Restriction {
+			case auth.RestrictionApprovalPending:
+				c.JSON(403, gin.H{"message": "Professor account is not approved", "missing": permission})
+			case auth.RestrictionMFASetupRequired:
+				c.JSON(403, gin.H{"message": "Two-factor authentication required", "mfaSetupRequired": true, "missing": permission})
+			default:
+				c.JSON(403, gin.H{"message": "Forbidden", "missing": permission})
+			}
 			c.Abort()
 			return
 		}
+		c.Set("permissions", grants.Permissions)
 		c.Next()
 	}
 }
 
-func RequireStudent() gin.HandlerFunc {
+func RequireVerifiedEmail() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleVal, _ := c.Get("role")
-		role := roleVal.(int8)
-		if role != models.RoleStudent {
-			c.JSON(403, gin.H{"message": "Forbidden", "role": role, "shouldBe": models.RoleStudent})
+		user := models.User{}
+		err := database.DB.Select("id", "verified_at").Where("id = ?", c.GetUint("userID")).First(&user).Error
+		if err != nil || !user.IsVerified() {
+			c.JSON(403, gin.H{"message": "Email not verified"})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AuditEvent records a security or administrative action. ActorID is 0 when
+// nobody was authenticated, e.g. for login lockouts.
+type AuditEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorID    uint      `gorm:"index" json:"actorId"`
+	Action     string    `gorm:"not null;index" json:"action"`
+	TargetType string    `json:"targetType"`
+	TargetID   uint      `json:"targetId"`
+	IP         string    `json:"ip"`
+	Details    string    `json:"details"`
+	CreatedAt  time.Time `gorm:"not null" json:"createdAt"`
+}
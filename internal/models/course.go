@@ -1,16 +1,81 @@
 package models
 
 import (
+	"crypto/rand"
+	"math/big"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Course codes are unique among courses that aren't soft-deleted, so the
+// code of a deleted course can be reused. Code is the public catalog code;
+// students join with the secret JoinCode instead. When RequiresApproval is
+// set, joining creates a JoinRequest that staff have to approve. Once
+// Capacity students are enrolled, new students go on the waitlist. Courses
+// are archived, and become read-only, when their term ends.
 type Course struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	Name        string         `gorm:"not null" json:"name"`
-	Code        string         `gorm:"not null" json:"code"`
-	ProfessorID uint           `gorm:"not null" json:"professorId"`
-	CreatedAt   time.Time      `gorm:"not null" json:"createdAt"`
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	Name              string         `gorm:"not null" json:"name"`
+	Code              string         `gorm:"not null;uniqueIndex:idx_courses_code_active,where:deleted_at IS NULL" json:"code"`
+	ProfessorID       uint           `gorm:"not null" json:"professorId"`
+	JoinCode          string         `gorm:"uniqueIndex:idx_courses_join_code,where:join_code <> ''" json:"-"`
+	JoinCodeExpiresAt *time.Time     `json:"-"`
+	JoinCodeMaxUses   *int           `json:"-"`
+	JoinCodeUses      int            `gorm:"not null;default:0" json:"-"`
+	RequiresApproval  bool           `gorm:"not null;default:false" json:"requiresApproval"`
+	Capacity          *int           `json:"capacity"`
+	TermID            *uint          `gorm:"index" json:"termId"`
+	ArchivedAt        *time.Time     `json:"archivedAt"`
+	CreatedAt         time.Time      `gorm:"not null" json:"createdAt"`
+	UpdatedAt         time.Time      `json:"updatedAt"`
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+}
+
+// joinCodeAlphabet leaves out characters that are easy to confuse, like 0/O
+// and 1/I.
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const joinCodeLength = 8
+
+func NewJoinCode() (string, error) {
+	code := make([]byte, joinCodeLength)
+	max := big.NewInt(int64(len(joinCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = joinCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeJoinCode makes codes typed as "abcd-efgh" match "ABCDEFGH".
+func NormalizeJoinCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func (c Course) Archived() bool {
+	return c.ArchivedAt != nil
+}
+
+// JoinCodeUsable reports whether the join code can still admit a student.
+func (c Course) JoinCodeUsable(now time.Time) bool {
+	return joinCodeUsable(c.JoinCode, c.JoinCodeExpiresAt, c.JoinCodeMaxUses, c.JoinCodeUses, now)
+}
+
+func joinCodeUsable(code string, expiresAt *time.Time, maxUses *int, uses int, now time.Time) bool {
+	if code == "" {
+		return false
+	}
+	if expiresAt != nil && now.After(*expiresAt) {
+		return false
+	}
+	if maxUses != nil && uses >= *maxUses {
+		return false
+	}
+	return true
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJoinCodeUsable(t *testing.T) {
+	now := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	limit := func(n int) *int {
+		return &n
+	}
+	tests := []struct {
+		name      string
+		code      string
+		expiresAt *time.Time
+		maxUses   *int
+		uses      int
+		want      bool
+	}{
+		{name: "no limits", code: "ABCD1234", uses: 500, want: true},
+		{name: "no code", code: ""},
+		{name: "expired", code: "ABCD1234", expiresAt: &past},
+		{name: "expires exactly now", code: "ABCD1234", expiresAt: &now, want: true},
+		{name: "not expired", code: "ABCD1234", expiresAt: &future, want: true},
+		{name: "uses left", code: "ABCD1234", maxUses: limit(3), uses: 2, want: true},
+		{name: "used up", code: "ABCD1234", maxUses: limit(3), uses: 3},
+		{name: "over used", code: "ABCD1234", maxUses: limit(3), uses: 4},
+		{name: "uses left but expired", code: "ABCD1234", expiresAt: &past, maxUses: limit(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			course := Course{JoinCode: tt.code, JoinCodeExpiresAt: tt.expiresAt, JoinCodeMaxUses: tt.maxUses, JoinCodeUses: tt.uses}
+			if got := course.JoinCodeUsable(now); got != tt.want {
+				t.Errorf("course: JoinCodeUsable = %v, want %v", got, tt.want)
+			}
+			section := Section{JoinCode: tt.code, JoinCodeExpiresAt: tt.expiresAt, JoinCodeMaxUses: tt.maxUses, JoinCodeUses: tt.uses}
+			if got := section.JoinCodeUsable(now); got != tt.want {
+				t.Errorf("section: JoinCodeUsable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeJoinCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"ABCD1234", "ABCD1234"},
+		{"abcd-1234", "ABCD1234"},
+		{" ab cd 12-34 ", "ABCD1234"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeJoinCode(tt.in); got != tt.want {
+			t.Errorf("NormalizeJoinCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,34 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+const (
+	EnrollmentActive    = "active"
+	EnrollmentDropped   = "dropped"
+	EnrollmentRemoved   = "removed"
+	EnrollmentCompleted = "completed"
+)
+
+// Enrollment is a student's membership of a course. Rows are kept when it
+// ends: leaving marks it dropped, staff removal marks it removed, and joining
+// again reactivates the same row. EnrolledAt is when it last became active
+// and EndedAt when it last stopped being active. Enrollments are soft-deleted
+// together with their course and restored with it.
 type Enrollment struct {
-	ID uint `gorm:"primaryKey"`
-	UserID uint `gorm:"not null"`
-	CourseID uint `gorm:"not null"`
-	CreatedAt time.Time `gorm:"not null"`
-	User User `gorm:"foreignKey:UserID"`
-	Course Course `gorm:"foreignKey:CourseID"`
-}
\ No newline at end of file
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_enrollments_user_course"`
+	CourseID   uint      `gorm:"not null;uniqueIndex:idx_enrollments_user_course;index"`
+	SectionID  *uint     `gorm:"index"`
+	Status     string    `gorm:"not null;default:active"`
+	CreatedAt  time.Time `gorm:"not null"`
+	EnrolledAt time.Time
+	EndedAt    *time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	User       User           `gorm:"foreignKey:UserID"`
+	Course     Course         `gorm:"foreignKey:CourseID"`
+	Section    *Section       `gorm:"foreignKey:SectionID"`
+}
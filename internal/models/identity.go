@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"userId"`
+	Issuer    string    `gorm:"not null;uniqueIndex:idx_identity_issuer_subject" json:"issuer"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_identity_issuer_subject" json:"subject"`
+	CreatedAt time.Time `gorm:"not null" json:"createdAt"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+}
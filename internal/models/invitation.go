@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+)
+
+// InvitationTTL is how long an invitation's accept link works.
+const InvitationTTL = 14 * 24 * time.Hour
+
+// Invitation invites an email address to a course, in SectionID if it is
+// set. It is accepted through a signed link, or automatically when the email
+// registers. Emails are stored lowercased and have at most one pending
+// invitation per course.
+type Invitation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"not null;uniqueIndex:idx_invitations_pending,where:status = 'pending'" json:"courseId"`
+	Email     string    `gorm:"not null;index;uniqueIndex:idx_invitations_pending,where:status = 'pending'" json:"email"`
+	Name      string    `gorm:"default:''" json:"name"`
+	SectionID *uint     `json:"sectionId"`
+	Status    string    `gorm:"not null;default:pending" json:"status"`
+	InvitedBy uint      `gorm:"not null" json:"invitedBy"`
+	CreatedAt time.Time `gorm:"not null" json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// AcceptedBy is the user that accepted it.
+	AcceptedBy *uint      `json:"acceptedBy"`
+	AcceptedAt *time.Time `json:"acceptedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	Course     Course     `gorm:"foreignKey:CourseID" json:"-"`
+}
+
+func (i Invitation) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
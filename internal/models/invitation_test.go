@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvitationExpired(t *testing.T) {
+	expiresAt := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	invitation := Invitation{ExpiresAt: expiresAt}
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{name: "before", now: expiresAt.Add(-time.Second)},
+		{name: "at expiry", now: expiresAt, want: true},
+		{name: "after", now: expiresAt.Add(time.Second), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := invitation.Expired(tt.now); got != tt.want {
+				t.Errorf("Expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestDenied   = "denied"
+)
+
+// JoinRequest is created instead of an Enrollment when a student joins a
+// course that requires approval. A student has at most one pending request
+// per course.
+type JoinRequest struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CourseID  uint       `gorm:"not null;uniqueIndex:idx_join_requests_pending,where:status = 'pending'" json:"courseId"`
+	UserID    uint       `gorm:"not null;index;uniqueIndex:idx_join_requests_pending,where:status = 'pending'" json:"userId"`
+	SectionID *uint      `json:"sectionId"`
+	Status    string     `gorm:"not null;default:pending" json:"status"`
+	DecidedBy *uint      `json:"decidedBy"`
+	DecidedAt *time.Time `json:"decidedAt"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `gorm:"not null" json:"createdAt"`
+	Course    Course     `gorm:"foreignKey:CourseID" json:"-"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CourseRemoval records a student being removed from a course by its staff.
+// While Blocked is set the student can't rejoin the course.
+type CourseRemoval struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CourseID    uint       `gorm:"not null;index" json:"courseId"`
+	UserID      uint       `gorm:"not null;index" json:"userId"`
+	RemovedBy   uint       `gorm:"not null" json:"removedBy"`
+	Reason      string     `gorm:"not null" json:"reason"`
+	Blocked     bool       `gorm:"not null;default:false" json:"blocked"`
+	UnblockedAt *time.Time `json:"unblockedAt"`
+	CreatedAt   time.Time  `gorm:"not null" json:"createdAt"`
+	Course      Course     `gorm:"foreignKey:CourseID" json:"-"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+const (
+	PermCourseCreate   = "course.create"
+	PermCourseView     = "course.view"
+	PermCourseUpdate   = "course.update"
+	PermCourseDelete   = "course.delete"
+	PermRosterRead     = "roster.read"
+	PermRosterManage   = "roster.manage"
+	PermStaffManage    = "staff.manage"
+	PermGradesRead     = "grades.read"
+	PermGradesWrite    = "grades.write"
+	PermEnrollmentSelf = "enrollment.self"
+	PermUsersApprove   = "users.approve"
+	PermTermsManage    = "terms.manage"
+)
+
+const (
+	RoleScopeGlobal = "global"
+	RoleScopeCourse = "course"
+)
+
+// Global role names line up with User.Role; course role names are granted
+// per course through CourseRoleAssignment. The course's own professor
+// implicitly holds CourseRoleInstructor.
+const (
+	GlobalRoleStudent   = "student"
+	GlobalRoleProfessor = "professor"
+	GlobalRoleAdmin     = "admin"
+
+	CourseRoleInstructor   = "instructor"
+	CourseRoleCoInstructor = "co_instructor"
+	CourseRoleTA           = "ta"
+	CourseRoleGrader       = "grader"
+	CourseRoleAuditor      = "auditor"
+)
+
+type AccessRole struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	Name        string           `gorm:"not null;uniqueIndex" json:"name"`
+	Scope       string           `gorm:"not null" json:"scope"`
+	CreatedAt   time.Time        `gorm:"not null" json:"createdAt"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleID" json:"permissions"`
+}
+
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	RoleID     uint   `gorm:"not null;uniqueIndex:idx_role_permission" json:"-"`
+	Permission string `gorm:"not null;uniqueIndex:idx_role_permission" json:"permission"`
+}
+
+type CourseRoleAssignment struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CourseID  uint       `gorm:"not null;uniqueIndex:idx_course_role_assignment" json:"courseId"`
+	UserID    uint       `gorm:"not null;uniqueIndex:idx_course_role_assignment;index" json:"userId"`
+	RoleID    uint       `gorm:"not null;uniqueIndex:idx_course_role_assignment" json:"roleId"`
+	CreatedAt time.Time  `gorm:"not null" json:"createdAt"`
+	Role      AccessRole `gorm:"foreignKey:RoleID" json:"role"`
+}
+
+// StaffInvitation offers a user a course role. The role is only granted,
+// as a CourseRoleAssignment, once the user accepts. A user has at most one
+// open offer per course.
+type StaffInvitation struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CourseID  uint       `gorm:"not null;uniqueIndex:idx_staff_invitation" json:"courseId"`
+	UserID    uint       `gorm:"not null;uniqueIndex:idx_staff_invitation;index" json:"userId"`
+	RoleID    uint       `gorm:"not null" json:"roleId"`
+	InvitedBy uint       `gorm:"not null" json:"invitedBy"`
+	CreatedAt time.Time  `gorm:"not null" json:"createdAt"`
+	Role      AccessRole `gorm:"foreignKey:RoleID" json:"role"`
+	Course    Course     `gorm:"foreignKey:CourseID" json:"-"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// DefaultRoles are created on startup. Permissions added to a role in the
+// database are kept; removed default permissions come back on restart.
+var DefaultRoles = []struct {
+	Name        string
+	Scope       string
+	Permissions []string
+}{
+	{GlobalRoleStudent, RoleScopeGlobal, []string{PermEnrollmentSelf}},
+	{GlobalRoleProfessor, RoleScopeGlobal, []string{PermCourseCreate}},
+	{GlobalRoleAdmin, RoleScopeGlobal, []string{PermUsersApprove, PermTermsManage, PermCourseView, PermCourseUpdate, PermCourseDelete, PermRosterRead}},
+	{CourseRoleInstructor, RoleScopeCourse, []string{PermCourseView, PermCourseUpdate, PermCourseDelete, PermRosterRead, PermRosterManage, PermStaffManage, PermGradesRead, PermGradesWrite}},
+	{CourseRoleCoInstructor, RoleScopeCourse, []string{PermCourseView, PermCourseUpdate, PermRosterRead, PermRosterManage, PermGradesRead, PermGradesWrite}},
+	{CourseRoleTA, RoleScopeCourse, []string{PermCourseView, PermRosterRead, PermGradesRead}},
+	{CourseRoleGrader, RoleScopeCourse, []string{PermCourseView, PermGradesRead, PermGradesWrite}},
+	{CourseRoleAuditor, RoleScopeCourse, []string{PermCourseView}},
+}
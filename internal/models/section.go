@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Section is a lecture or lab group within a course. Joining with a
+// section's own join code enrolls the student in the course and places them
+// in the section; the code can expire and be limited in uses like the
+// course's. Capacity limits the section's active enrollments.
+type Section struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	CourseID          uint       `gorm:"not null;uniqueIndex:idx_sections_course_name" json:"courseId"`
+	Name              string     `gorm:"not null;uniqueIndex:idx_sections_course_name" json:"name"`
+	JoinCode          string     `gorm:"uniqueIndex:idx_sections_join_code,where:join_code <> ''" json:"-"`
+	JoinCodeExpiresAt *time.Time `json:"-"`
+	JoinCodeMaxUses   *int       `json:"-"`
+	JoinCodeUses      int        `gorm:"not null;default:0" json:"-"`
+	Capacity          *int       `json:"capacity"`
+	CreatedAt         time.Time  `gorm:"not null" json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+}
+
+// JoinCodeUsable reports whether the section's join code can still admit a
+// student.
+func (s Section) JoinCodeUsable(now time.Time) bool {
+	return joinCodeUsable(s.JoinCode, s.JoinCodeExpiresAt, s.JoinCodeMaxUses, s.JoinCodeUses, now)
+}
+
+// SectionStaff assigns a member of the course staff to a section.
+type SectionStaff struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SectionID uint      `gorm:"not null;uniqueIndex:idx_section_staff" json:"sectionId"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_section_staff;index" json:"userId"`
+	CreatedAt time.Time `gorm:"not null" json:"createdAt"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// TermDateLayout is the format of term dates in requests and responses.
+const TermDateLayout = "2006-01-02"
+
+// Term is an academic term such as a semester. Both dates are inclusive;
+// courses in the term are archived once EndDate has passed.
+type Term struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"not null;uniqueIndex"`
+	StartDate time.Time `gorm:"type:date;not null"`
+	EndDate   time.Time `gorm:"type:date;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+// Ended reports whether the term's last day is over, in the server's time
+// zone.
+func (t Term) Ended(now time.Time) bool {
+	return t.EndDate.Format(TermDateLayout) < now.Format(TermDateLayout)
+}
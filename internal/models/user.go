@@ -5,13 +5,40 @@ import "time"
 const (
 	RoleStudent int8 = iota + 1
 	RoleProfessor
+	RoleAdmin
+)
+
+const (
+	ApprovalApproved = "approved"
+	ApprovalPending  = "pending"
+	ApprovalRejected = "rejected"
 )
 
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Name         string    `gorm:"default:''" json:"name"`
-	Email        string    `gorm:"uniqueIndex;not null" json:"email"`
-	PasswordHash string    `gorm:"not null" json:"passwordHash"`
-	Role         int8      `gorm:"not null" json:"role"`
-	CreatedAt    time.Time `gorm:"not null" json:"createdAt"`
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Name         string `gorm:"default:''" json:"name"`
+	Email        string `gorm:"uniqueIndex;not null" json:"email"`
+	PasswordHash string `gorm:"not null" json:"passwordHash"`
+	Role         int8   `gorm:"not null" json:"role"`
+	// ApprovalStatus gates professor accounts; students are always approved.
+	ApprovalStatus string     `gorm:"not null;default:'approved'" json:"approvalStatus"`
+	CreatedAt      time.Time  `gorm:"not null" json:"createdAt"`
+	VerifiedAt     *time.Time `json:"verifiedAt"`
+	// MFASecret is set during TOTP enrollment; two-factor is only on once
+	// MFAEnabledAt is set. MFALastStep blocks replay of a used code.
+	MFASecret    string     `gorm:"default:''" json:"-"`
+	MFAEnabledAt *time.Time `json:"mfaEnabledAt"`
+	MFALastStep  int64      `gorm:"default:0" json:"-"`
+}
+
+func (u User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+func (u User) MFAEnabled() bool {
+	return u.MFAEnabledAt != nil
+}
+
+func (u User) IsApprovedProfessor() bool {
+	return u.Role == RoleProfessor && u.ApprovalStatus == ApprovalApproved
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// WaitlistEntry holds a student's place in line for a full course. Entries
+// are served in ID order, skipping students whose section is full.
+type WaitlistEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"not null;uniqueIndex:idx_waitlist_entries_course_user" json:"courseId"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_waitlist_entries_course_user" json:"userId"`
+	SectionID *uint     `json:"sectionId"`
+	CreatedAt time.Time `gorm:"not null" json:"createdAt"`
+	Course    Course    `gorm:"foreignKey:CourseID" json:"-"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+}
@@ -0,0 +1,346 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNotConfigured = errors.New("OIDC is not configured")
+	ErrInvalidToken  = errors.New("invalid ID token")
+)
+
+// Provider is an OpenID Connect relying party for one identity provider,
+// configured from OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET,
+// OIDC_REDIRECT_URL and optionally OIDC_SCOPES.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	mu        sync.Mutex
+	discovery *discovery
+	jwks      map[string]interface{}
+	jwksAt    time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the ID token claims Conductor uses.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultProvider *Provider
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// jwksTTL bounds how long provider keys are cached before being refetched.
+const jwksTTL = time.Hour
+
+// Default returns the provider configured through the environment, or
+// ErrNotConfigured when OIDC_ISSUER or OIDC_CLIENT_ID is missing.
+func Default() (*Provider, error) {
+	defaultOnce.Do(func() {
+		issuer := strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/")
+		clientID := os.Getenv("OIDC_CLIENT_ID")
+		if issuer == "" || clientID == "" {
+			return
+		}
+		scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+		defaultProvider = &Provider{
+			Issuer:       issuer,
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       scopes,
+		}
+	})
+	if defaultProvider == nil {
+		return nil, ErrNotConfigured
+	}
+	return defaultProvider, nil
+}
+
+func (p *Provider) discover() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var d discovery
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", d.Issuer, p.Issuer)
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// NewPKCE returns a random code verifier and its S256 code challenge.
+func NewPKCE() (verifier string, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthURL builds the authorization request the browser is redirected to.
+func (p *Provider) AuthURL(state string, nonce string, codeChallenge string) (string, error) {
+	d, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token
+// claims.
+func (p *Provider) Exchange(code string, codeVerifier string, nonce string) (Claims, error) {
+	d, err := p.discover()
+	if err != nil {
+		return Claims{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	resp, err := httpClient.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Claims{}, err
+	}
+	if body.IDToken == "" {
+		return Claims{}, errors.New("token response has no id_token")
+	}
+	return p.verify(body.IDToken, nonce)
+}
+
+func (p *Provider) verify(idToken string, nonce string) (Claims, error) {
+	token, err := jwt.Parse(idToken, p.keyFor,
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+	)
+	if err != nil || !token.Valid {
+		return Claims{}, ErrInvalidToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["nonce"] != nonce {
+		return Claims{}, ErrInvalidToken
+	}
+	result := Claims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+	if result.Subject == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	return result, nil
+}
+
+func (p *Provider) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := p.lookupKey(kid, false)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		// The provider may have rotated its keys since they were cached.
+		key, err = p.lookupKey(kid, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key == nil {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+func (p *Provider) lookupKey(kid string, refresh bool) (interface{}, error) {
+	d, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if refresh || p.jwks == nil || time.Since(p.jwksAt) > jwksTTL {
+		keys, err := fetchJWKS(d.JWKSURI)
+		if err != nil {
+			return nil, err
+		}
+		p.jwks = keys
+		p.jwksAt = time.Now()
+	}
+	if kid == "" && len(p.jwks) == 1 {
+		for _, key := range p.jwks {
+			return key, nil
+		}
+	}
+	return p.jwks[kid], nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Use string `json:"use"`
+}
+
+func fetchJWKS(uri string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(uri, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func getJSON(uri string, v interface{}) error {
+	resp, err := httpClient.Get(uri)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", uri, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,23 @@
+package ratelimit
+
+import (
+	"time"
+
+	"conductor_backend/internal/database"
+)
+
+// Allow counts one hit against key and reports whether it is still within
+// limit for the current fixed window.
+func Allow(key string, limit int64, window time.Duration) (bool, error) {
+	redisKey := "ratelimit:" + key
+	count, err := database.RDB.Incr(database.Ctx, redisKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		if err := database.RDB.Expire(database.Ctx, redisKey, window).Err(); err != nil {
+			return false, err
+		}
+	}
+	return count <= limit, nil
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"conductor_backend/internal/controllers"
 	"conductor_backend/internal/middleware"
+	"conductor_backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,21 +12,88 @@ func RegisterRoutes(r *gin.Engine) {
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
 	r.POST("/users/register", controllers.Register)
 	r.POST("/users/login", controllers.Login)
+	r.POST("/users/login/mfa", controllers.LoginMFA)
+	r.POST("/users/refresh", controllers.Refresh)
+	r.POST("/users/logout", controllers.Logout)
+	r.POST("/users/password/forgot", controllers.ForgotPassword)
+	r.POST("/users/password/reset", controllers.ResetPassword)
+	r.POST("/users/verify", controllers.VerifyEmail)
+	r.POST("/users/verify/resend", controllers.ResendVerification)
+	r.POST("/invitations/preview", controllers.PreviewInvitation)
+	r.GET("/auth/oidc/login", controllers.OIDCLogin)
+	r.GET("/auth/oidc/callback", controllers.OIDCCallback)
+	r.POST("/auth/oidc/exchange", controllers.OIDCExchange)
 
 	auth := r.Group("/")
 	auth.Use(middleware.AuthMiddleware())
 	{
 		auth.GET("/me", controllers.Me)
-		auth.POST("/courses", middleware.RequireProfessor(), controllers.CreateCourse)
-		auth.DELETE("/courses/:id", middleware.RequireProfessor(), controllers.DeleteCourse)
-		auth.GET("/courses/:id/delete-info", middleware.RequireProfessor(), controllers.GetCourseDeleteInfo)
+		auth.GET("/me/sessions", controllers.ListSessions)
+		auth.DELETE("/me/sessions", controllers.RevokeAllSessions)
+		auth.DELETE("/me/sessions/:id", controllers.RevokeSession)
+		auth.POST("/me/mfa/setup", controllers.SetupMFA)
+		auth.POST("/me/mfa/enable", controllers.EnableMFA)
+		auth.POST("/me/mfa/disable", controllers.DisableMFA)
+		auth.POST("/me/mfa/recovery-codes", controllers.RegenerateRecoveryCodes)
+		auth.POST("/courses", middleware.RequirePermission(models.PermCourseCreate), controllers.CreateCourse)
+		auth.PATCH("/courses/:id", middleware.RequirePermission(models.PermCourseUpdate), middleware.RequireActiveCourse(), controllers.UpdateCourse)
+		auth.DELETE("/courses/:id", middleware.RequirePermission(models.PermCourseDelete), controllers.DeleteCourse)
+		auth.GET("/courses/:id/delete-info", middleware.RequirePermission(models.PermCourseDelete), controllers.GetCourseDeleteInfo)
+		auth.GET("/courses/:id/roster", middleware.RequirePermission(models.PermRosterRead), controllers.GetCourseRoster)
+		auth.GET("/courses/:id/roster/export", middleware.RequirePermission(models.PermRosterRead), controllers.ExportRoster)
+		auth.POST("/courses/:id/roster/import", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.ImportRoster)
+		auth.GET("/courses/:id/waitlist", middleware.RequirePermission(models.PermRosterRead), controllers.GetCourseWaitlist)
+		auth.DELETE("/courses/:id/students/:userId", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.RemoveStudent)
+		auth.GET("/courses/:id/blocks", middleware.RequirePermission(models.PermRosterManage), controllers.ListCourseBlocks)
+		auth.DELETE("/courses/:id/blocks/:userId", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.UnblockStudent)
+		auth.GET("/courses/:id/join-requests", middleware.RequirePermission(models.PermRosterManage), controllers.ListJoinRequests)
+		auth.POST("/courses/:id/join-requests/approve", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.ApproveJoinRequests)
+		auth.POST("/courses/:id/join-requests/deny", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.DenyJoinRequests)
+		auth.PUT("/courses/:id/students/:userId/section", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.SetStudentSection)
+		auth.POST("/courses/:id/message", middleware.RequirePermission(models.PermRosterManage), controllers.MessageStudents)
+		auth.GET("/courses/:id/sections", middleware.RequirePermission(models.PermCourseView), controllers.ListSections)
+		auth.POST("/courses/:id/sections", middleware.RequirePermission(models.PermCourseUpdate), middleware.RequireActiveCourse(), controllers.CreateSection)
+		auth.PATCH("/courses/:id/sections/:sectionId", middleware.RequirePermission(models.PermCourseUpdate), middleware.RequireActiveCourse(), controllers.UpdateSection)
+		auth.DELETE("/courses/:id/sections/:sectionId", middleware.RequirePermission(models.PermCourseUpdate), middleware.RequireActiveCourse(), controllers.DeleteSection)
+		auth.GET("/courses/:id/sections/:sectionId/join-code", middleware.RequirePermission(models.PermRosterManage), controllers.GetSectionJoinCode)
+		auth.POST("/courses/:id/sections/:sectionId/join-code", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.RegenerateSectionJoinCode)
+		auth.POST("/courses/:id/sections/:sectionId/staff", middleware.RequirePermission(models.PermStaffManage), middleware.RequireActiveCourse(), controllers.AddSectionStaff)
+		auth.DELETE("/courses/:id/sections/:sectionId/staff/:userId", middleware.RequirePermission(models.PermStaffManage), middleware.RequireActiveCourse(), controllers.RemoveSectionStaff)
+		auth.GET("/courses/:id/invitations", middleware.RequirePermission(models.PermRosterManage), controllers.ListInvitations)
+		auth.POST("/courses/:id/invitations", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.CreateInvitation)
+		auth.DELETE("/courses/:id/invitations/:invitationId", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.RevokeInvitation)
+		auth.GET("/courses/:id/join-code", middleware.RequirePermission(models.PermRosterManage), controllers.GetJoinCode)
+		auth.POST("/courses/:id/join-code", middleware.RequirePermission(models.PermRosterManage), middleware.RequireActiveCourse(), controllers.RegenerateJoinCode)
+		auth.GET("/courses/:id/staff", middleware.RequirePermission(models.PermCourseView), controllers.ListCourseStaff)
+		auth.POST("/courses/:id/staff", middleware.RequirePermission(models.PermStaffManage), middleware.RequireActiveCourse(), controllers.AddCourseStaff)
+		auth.DELETE("/courses/:id/staff/:userId", middleware.RequirePermission(models.PermStaffManage), middleware.RequireActiveCourse(), controllers.RemoveCourseStaff)
 		auth.GET("/courses", controllers.GetCourseByUserID)
-		auth.POST("/courses/join", middleware.RequireStudent(), controllers.JoinCourse)
-		auth.DELETE("/courses/:id/leave", middleware.RequireStudent(), controllers.LeaveCourse)
-		auth.GET("/courses/enrolled", middleware.RequireStudent(), controllers.GetEnrollmentsByStudentID)
+		auth.GET("/courses/trash", controllers.ListTrash)
+		auth.POST("/courses/trash/:id/restore", middleware.RequirePermission(models.PermCourseDelete), controllers.RestoreCourse)
+		auth.POST("/courses/join", middleware.RequirePermission(models.PermEnrollmentSelf), middleware.RequireVerifiedEmail(), controllers.JoinCourse)
+		auth.DELETE("/courses/:id/leave", middleware.RequirePermission(models.PermEnrollmentSelf), middleware.RequireActiveCourse(), controllers.LeaveCourse)
+		auth.GET("/courses/enrolled", middleware.RequirePermission(models.PermEnrollmentSelf), controllers.GetEnrollmentsByStudentID)
+		auth.GET("/courses/removals", middleware.RequirePermission(models.PermEnrollmentSelf), controllers.GetMyRemovals)
+		auth.POST("/invitations/accept", middleware.RequirePermission(models.PermEnrollmentSelf), controllers.AcceptInvitation)
+		auth.GET("/staff-invitations", controllers.ListStaffInvitations)
+		auth.POST("/staff-invitations/:invitationId/accept", controllers.AcceptStaffInvitation)
+		auth.POST("/staff-invitations/:invitationId/decline", controllers.DeclineStaffInvitation)
 		auth.POST("/users/name", controllers.SetName)
+		auth.GET("/terms", controllers.ListTerms)
+		auth.POST("/terms", middleware.RequirePermission(models.PermTermsManage), controllers.CreateTerm)
+		auth.PATCH("/terms/:id", middleware.RequirePermission(models.PermTermsManage), controllers.UpdateTerm)
+	}
+
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(), middleware.RequirePermission(models.PermUsersApprove))
+	{
+		admin.GET("/professors/pending", controllers.ListPendingProfessors)
+		admin.POST("/professors/:id/approve", controllers.ApproveProfessor)
+		admin.POST("/professors/:id/reject", controllers.RejectProfessor)
+		admin.GET("/roles", controllers.ListRoles)
 	}
 
 	dev := r.Group("/dev")
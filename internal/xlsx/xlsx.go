@@ -0,0 +1,133 @@
+// Package xlsx writes single-sheet XLSX workbooks one row at a time, so large
+// exports never have to be held in memory. Every cell is written as text.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const contentTypes = `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const rootRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbookRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const (
+	workbookStart = `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`
+	workbookEnd = `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	sheetStart  = `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetEnd    = `</sheetData></worksheet>`
+)
+
+// Writer streams rows into the only sheet of a workbook. Call Close to
+// finish the file.
+type Writer struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	rows  int
+	buf   bytes.Buffer
+}
+
+// NewWriter starts a workbook on w with one sheet called sheetName.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	z := zip.NewWriter(w)
+	var workbook bytes.Buffer
+	workbook.WriteString(xml.Header + workbookStart)
+	xml.EscapeText(&workbook, []byte(cleanSheetName(sheetName)))
+	workbook.WriteString(workbookEnd)
+	parts := []struct {
+		name string
+		data string
+	}{
+		{"[Content_Types].xml", xml.Header + contentTypes},
+		{"_rels/.rels", xml.Header + rootRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", xml.Header + workbookRels},
+	}
+	for _, part := range parts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.data); err != nil {
+			return nil, err
+		}
+	}
+	// The sheet has to be the last part, since it is written row by row.
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xml.Header+sheetStart); err != nil {
+		return nil, err
+	}
+	return &Writer{zip: z, sheet: sheet}, nil
+}
+
+// Write appends a row.
+func (w *Writer) Write(record []string) error {
+	w.rows++
+	row := strconv.Itoa(w.rows)
+	w.buf.Reset()
+	w.buf.WriteString(`<row r="` + row + `">`)
+	for i, value := range record {
+		w.buf.WriteString(`<c r="` + columnName(i) + row + `" t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(&w.buf, []byte(value))
+		w.buf.WriteString(`</t></is></c>`)
+	}
+	w.buf.WriteString(`</row>`)
+	_, err := w.sheet.Write(w.buf.Bytes())
+	return err
+}
+
+// Close finishes the sheet and the workbook. It doesn't close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if _, err := io.WriteString(w.sheet, sheetEnd); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+// columnName turns a zero-based column index into its letters: A, B, ...,
+// Z, AA, AB and so on.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// cleanSheetName drops the characters spreadsheet apps don't allow in sheet
+// names and cuts it to their 31 character limit.
+func cleanSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if strings.TrimSpace(name) == "" {
+		return "Sheet1"
+	}
+	return name
+}
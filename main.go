@@ -1,7 +1,10 @@
 package main
 
 import (
+	"conductor_backend/internal/auth"
 	"conductor_backend/internal/database"
+	"conductor_backend/internal/jobs"
+	"conductor_backend/internal/mailer"
 	"conductor_backend/internal/routes"
 
 	"log"
@@ -21,10 +24,23 @@ func main() {
 	log.Println("Starting server...")
 	database.ConnectPostgreSQL()
 	database.ConnectRedis()
+	for _, userID := range database.PromoteAdmins() {
+		auth.InvalidatePermissions(userID)
+	}
+	mailer.Init()
+	if err := auth.LoadKeys(); err != nil {
+		panic(err)
+	}
+	jobs.Start()
 	r := gin.Default()
+	// ClientIP keys rate limits, sessions and the audit log, so only proxies
+	// we run may set X-Forwarded-For.
+	if err := r.SetTrustedProxies(getTrustedProxies()); err != nil {
+		panic(err)
+	}
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     getCorsOrigins(),
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		AllowCredentials: true,
 	}))
@@ -32,6 +48,20 @@ func main() {
 	r.Run(":9916")
 }
 
+// getTrustedProxies reads TRUSTED_PROXIES, a comma-separated list of proxy
+// IPs or CIDRs. Unset trusts none and uses the connection's address.
+func getTrustedProxies() []string {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return nil
+	}
+	list := strings.Split(proxies, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
+
 func getCorsOrigins() []string {
 	origins := os.Getenv("CORS_ALLOWED_ORIGINS")
 	if origins == "" {